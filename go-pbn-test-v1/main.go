@@ -8,9 +8,38 @@ import (
 	"image/color"
 	"image/png"
 	"syscall/js"
+
+	// 注册解码器，image.Decode 根据文件头自动识别格式
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
+// processImage 将图片转换为灰度 PNG
+// 参数：file(File/Blob，支持 PNG/JPEG/GIF/BMP/TIFF/WebP)
+// 返回：Promise，结果为 PNG 文件内容的 Uint8Array
 func processImage(this js.Value, args []js.Value) interface{} {
+	return grayscalePromise(args, func(data js.Value, format string) js.Value {
+		return data
+	})
+}
+
+// processImageWithFormat 与 processImage 相同，同时返回识别出的原始格式
+// 返回：Promise，结果为 {data: Uint8Array, format: "png" | "jpeg" | "gif" | "bmp" | "tiff" | "webp"}
+func processImageWithFormat(this js.Value, args []js.Value) interface{} {
+	return grayscalePromise(args, func(data js.Value, format string) js.Value {
+		return js.ValueOf(map[string]interface{}{
+			"data":   data,
+			"format": format,
+		})
+	})
+}
+
+// grayscalePromise 解码 args[0] 中的文件并转换为灰度 PNG，result 根据 PNG 数据和格式生成 Promise 的结果
+func grayscalePromise(args []js.Value, result func(data js.Value, format string) js.Value) interface{} {
 	if len(args) < 1 {
 		fmt.Println("未提供文件")
 		return nil
@@ -36,7 +65,7 @@ func processImage(this js.Value, args []js.Value) interface{} {
 			js.CopyBytesToGo(byteSlice, uint8Array)
 
 			// 解码图片
-			img, _, err := image.Decode(bytes.NewReader(byteSlice))
+			img, format, err := image.Decode(bytes.NewReader(byteSlice))
 			if err != nil {
 				fmt.Println("解码图片失败:", err)
 				reject.Invoke(js.ValueOf(err.Error()))
@@ -66,7 +95,7 @@ func processImage(this js.Value, args []js.Value) interface{} {
 			js.CopyBytesToJS(resultUint8Array, buf.Bytes())
 
 			// 解析成功，调用 resolve
-			resolve.Invoke(resultUint8Array)
+			resolve.Invoke(result(resultUint8Array, format))
 			return nil
 		}))
 		return nil
@@ -76,8 +105,9 @@ func processImage(this js.Value, args []js.Value) interface{} {
 }
 
 func main() {
-	// 注册 processImage 函数到 JavaScript 的全局对象
+	// 注册 processImage 和 processImageWithFormat 函数到 JavaScript 的全局对象
 	js.Global().Set("processImage", js.FuncOf(processImage))
+	js.Global().Set("processImageWithFormat", js.FuncOf(processImageWithFormat))
 
 	// 防止 Go 程序退出
 	c := make(chan struct{}, 0)
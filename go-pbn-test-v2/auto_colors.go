@@ -0,0 +1,86 @@
+package main
+
+// 自动选择颜色数的默认参数
+const (
+	DEFAULT_AUTO_MAX_COLORS   = 64    // 搜索的最大颜色数
+	DEFAULT_AUTO_TARGET_ERROR = 100.0 // 每个像素在工作颜色空间中的平均误差平方
+	DEFAULT_AUTO_MIN_GAIN     = 0.005 // 增加一种颜色带来的误差下降占初始误差的比例
+)
+
+// AutoColorOptions 自动选择颜色数的选项，两个条件中任意一个满足即停止增加颜色
+type AutoColorOptions struct {
+	TargetError float64 // 平均误差平方不超过该值时停止，0 表示不使用
+	MinGain     float64 // 再增加一种颜色使误差下降不足初始误差的该比例时停止，0 表示不使用
+}
+
+// AutoColorResult 自动选择颜色数的结果
+type AutoColorResult struct {
+	Colors     int       // 最终调色板的颜色数，包括锁定颜色和透明颜色
+	Adaptive   int       // Wu 算法选择的颜色数
+	ErrorCurve []float64 // ErrorCurve[i] 为使用 i+1 种 Wu 颜色时每个像素的平均误差平方
+}
+
+// DefaultAutoColorOptions 返回默认的自动选择选项
+func DefaultAutoColorOptions() AutoColorOptions {
+	return AutoColorOptions{
+		TargetError: DEFAULT_AUTO_TARGET_ERROR,
+		MinGain:     DEFAULT_AUTO_MIN_GAIN,
+	}
+}
+
+// BuildPaletteAuto 自动选择颜色数并构建调色板
+// 创建 Quantizer 时的颜色数作为上限，先按 Wu 算法一直分割到上限并记录每次分割后的误差，
+// 再选择满足条件的最小颜色数重新分割
+func (q *Quantizer) BuildPaletteAuto(options AutoColorOptions) ([][4]uint8, *AutoColorResult) {
+	if q.fixedPalette {
+		return q.Palette, &AutoColorResult{Colors: len(q.Palette)}
+	}
+
+	q.calculateMoments()
+
+	result := &AutoColorResult{}
+	palette := q.assemblePalette(func(colors int) [][4]uint8 {
+		pixels := q.volume(q.Cubes[0], q.Weights)
+		q.Colors = colors
+		q.errorCurve = make([]float64, 0, colors)
+		q.preparePalette()
+
+		result.ErrorCurve = make([]float64, len(q.errorCurve))
+		for i, total := range q.errorCurve {
+			if pixels > 0 {
+				result.ErrorCurve[i] = total / pixels
+			}
+		}
+		q.errorCurve = nil
+
+		result.Adaptive = chooseColorCount(result.ErrorCurve, options)
+		q.resetCubes()
+		return q.buildAdaptivePalette(result.Adaptive)
+	})
+	result.Colors = len(palette)
+	return palette, result
+}
+
+// chooseColorCount 根据误差曲线选择颜色数
+func chooseColorCount(curve []float64, options AutoColorOptions) int {
+	for i := range curve {
+		if options.TargetError > 0 && curve[i] <= options.TargetError {
+			return i + 1
+		}
+		if options.MinGain > 0 && i+1 < len(curve) && curve[i]-curve[i+1] < options.MinGain*curve[0] {
+			return i + 1
+		}
+	}
+	if len(curve) == 0 {
+		return 1
+	}
+	return len(curve)
+}
+
+// cubeError 计算立方体内颜色与其平均值之间的误差平方和，空立方体为 0
+func (q *Quantizer) cubeError(cube *ColorCube) float64 {
+	if q.volume(cube, q.Weights) <= 0 {
+		return 0
+	}
+	return q.calculateVariance(cube)
+}
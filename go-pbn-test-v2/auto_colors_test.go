@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+// checkErrorCurve 检查误差曲线的长度与 Wu 颜色数一致且不会上升
+func checkErrorCurve(t *testing.T, quantizer *Quantizer, maxColors int) {
+	t.Helper()
+	quantizer.calculateMoments()
+	quantizer.Colors = maxColors
+	quantizer.errorCurve = make([]float64, 0, maxColors)
+	quantizer.preparePalette()
+	if len(quantizer.errorCurve) != quantizer.Colors {
+		t.Fatalf("error curve has %d entries for %d colors", len(quantizer.errorCurve), quantizer.Colors)
+	}
+	for i := 1; i < len(quantizer.errorCurve); i++ {
+		if quantizer.errorCurve[i] > quantizer.errorCurve[i-1]+1e-6 {
+			t.Fatalf("error curve rises at %d: %v", i, quantizer.errorCurve)
+		}
+	}
+}
+
+func TestErrorCurve(t *testing.T) {
+	checkErrorCurve(t, NewQuantizer(gradientBitmap(32, 16), 16), 16)
+
+	// 两种颜色落在同一个直方图单元内，立方体无法分割，每次分割都失败
+	bmp := NewBitmap(2, 1)
+	copy(bmp.Data, []uint8{0, 0, 0, 255, 3, 3, 3, 255})
+	checkErrorCurve(t, NewQuantizer(bmp, 8), 8)
+}
+
+func TestBuildPaletteAuto(t *testing.T) {
+	for _, options := range []AutoColorOptions{
+		{TargetError: 400},
+		{MinGain: 0.05},
+		DefaultAutoColorOptions(),
+	} {
+		quantizer := NewQuantizer(gradientBitmap(32, 16), 32)
+		palette, result := quantizer.BuildPaletteAuto(options)
+		if len(result.ErrorCurve) != 32 || result.Adaptive < 1 || result.Adaptive > 32 || result.Colors != len(palette) {
+			t.Fatalf("%+v: curve of %d, adaptive %d, colors %d, palette %d",
+				options, len(result.ErrorCurve), result.Adaptive, result.Colors, len(palette))
+		}
+
+		// 选择的颜色数满足其中一个条件，且更少的颜色数都不满足
+		satisfied := func(n int) bool {
+			curve := result.ErrorCurve
+			if options.TargetError > 0 && curve[n-1] <= options.TargetError {
+				return true
+			}
+			return options.MinGain > 0 && n < len(curve) && curve[n-1]-curve[n] < options.MinGain*curve[0]
+		}
+		if !satisfied(result.Adaptive) && result.Adaptive != len(result.ErrorCurve) {
+			t.Fatalf("%+v: %d colors do not meet the target, curve %v", options, result.Adaptive, result.ErrorCurve)
+		}
+		for n := 1; n < result.Adaptive; n++ {
+			if satisfied(n) {
+				t.Fatalf("%+v: %d colors already meet the target, chose %d", options, n, result.Adaptive)
+			}
+		}
+	}
+}
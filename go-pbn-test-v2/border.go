@@ -0,0 +1,344 @@
+package main
+
+// 边界方向，坐标系 y 轴向下
+const (
+	DIR_RIGHT = iota // +x
+	DIR_DOWN         // +y
+	DIR_LEFT         // -x
+	DIR_UP           // -y
+)
+
+// NO_FACET 表示图像外部
+const NO_FACET = -1
+
+// Point 像素网格的格点坐标，取值范围为 [0, Width] x [0, Height]
+type Point struct {
+	X, Y int
+}
+
+// BorderSegment 两个区域之间（或区域与图像边缘之间）的一段公共边界
+// 沿 Points 方向前进时，FacetA 位于右侧，FacetB 位于左侧
+type BorderSegment struct {
+	ID     int
+	FacetA int     // 追踪该边界的区域
+	FacetB int     // 边界另一侧的区域，图像边缘为 NO_FACET
+	Points []Point // 只保留拐点和端点
+	Closed bool    // 是否为首尾相接的闭合边界
+}
+
+// SegmentRef 边界环对边界段的引用
+type SegmentRef struct {
+	Segment int
+	Reverse bool // 是否需要反向使用该边界段
+}
+
+// BorderLoop 由若干边界段组成的闭合边界
+type BorderLoop struct {
+	Segments []SegmentRef
+	Hole     bool // 是否为区域内部的孔洞
+}
+
+// BorderResult 储存边界追踪的结果
+type BorderResult struct {
+	Width      uint32
+	Height     uint32
+	Segments   []*BorderSegment // 每段公共边界只储存一次
+	FacetLoops [][]*BorderLoop  // 索引为区域编号，第一个为外边界，其余为孔洞
+}
+
+// borderTracer 边界追踪过程中使用的状态
+type borderTracer struct {
+	width    int
+	height   int
+	facetMap []uint32
+	visited  []uint8 // 每个像素 4 个比特，分别对应像素的 4 条边
+	index    map[uint64]int
+	result   *BorderResult
+}
+
+// TraceBorders 追踪所有区域的边界，生成闭合折线
+// 相邻区域之间的公共边界只会被追踪一次，供两个区域共同引用
+func TraceBorders(facetResult *FacetResult) *BorderResult {
+	tracer := &borderTracer{
+		width:    int(facetResult.Width),
+		height:   int(facetResult.Height),
+		facetMap: facetResult.FacetMap.Data,
+		visited:  make([]uint8, facetResult.Width*facetResult.Height),
+		index:    make(map[uint64]int),
+		result: &BorderResult{
+			Width:      facetResult.Width,
+			Height:     facetResult.Height,
+			Segments:   make([]*BorderSegment, 0),
+			FacetLoops: make([][]*BorderLoop, len(facetResult.Facets)),
+		},
+	}
+
+	// 每个像素的上、右、下、左四条边依次对应从左上、右上、右下、左下格点出发的边
+	for y := 0; y < tracer.height; y++ {
+		for x := 0; x < tracer.width; x++ {
+			facet := tracer.facetAt(x, y)
+			starts := [4]struct {
+				x, y, dir int
+			}{
+				{x, y, DIR_RIGHT},
+				{x + 1, y, DIR_DOWN},
+				{x + 1, y + 1, DIR_LEFT},
+				{x, y + 1, DIR_UP},
+			}
+			for side, start := range starts {
+				if tracer.visited[y*tracer.width+x]&(1<<side) != 0 {
+					continue
+				}
+				if !tracer.isBorder(facet, start.x, start.y, start.dir) {
+					continue
+				}
+				tracer.traceLoop(facet, start.x, start.y, start.dir)
+			}
+		}
+	}
+
+	// 保证外边界排在孔洞前面
+	for _, loops := range tracer.result.FacetLoops {
+		for i, loop := range loops {
+			if !loop.Hole && i > 0 {
+				loops[0], loops[i] = loops[i], loops[0]
+				break
+			}
+		}
+	}
+
+	return tracer.result
+}
+
+// facetAt 返回像素所属的区域编号，图像外部返回 NO_FACET
+func (t *borderTracer) facetAt(x, y int) int {
+	if x < 0 || y < 0 || x >= t.width || y >= t.height {
+		return NO_FACET
+	}
+	return int(t.facetMap[y*t.width+x])
+}
+
+// sides 返回从格点 (x, y) 沿 dir 方向前进的单位边右侧和左侧的像素坐标
+func sides(x, y, dir int) (rx, ry, lx, ly int) {
+	switch dir {
+	case DIR_RIGHT:
+		return x, y, x, y - 1
+	case DIR_DOWN:
+		return x - 1, y, x, y
+	case DIR_LEFT:
+		return x - 1, y - 1, x - 1, y
+	default: // DIR_UP
+		return x, y - 1, x - 1, y - 1
+	}
+}
+
+// step 返回从格点沿 dir 方向前进一步后的格点
+func step(x, y, dir int) (int, int) {
+	switch dir {
+	case DIR_RIGHT:
+		return x + 1, y
+	case DIR_DOWN:
+		return x, y + 1
+	case DIR_LEFT:
+		return x - 1, y
+	default: // DIR_UP
+		return x, y - 1
+	}
+}
+
+// isBorder 判断单位边是否为 facet 的边界（facet 位于右侧，左侧为其它区域）
+func (t *borderTracer) isBorder(facet, x, y, dir int) bool {
+	rx, ry, lx, ly := sides(x, y, dir)
+	return t.facetAt(rx, ry) == facet && t.facetAt(lx, ly) != facet
+}
+
+// markVisited 标记单位边已被追踪
+func (t *borderTracer) markVisited(x, y, dir int) {
+	rx, ry, _, _ := sides(x, y, dir)
+	// 方向编号恰好对应单位边在右侧像素中的位置：上、右、下、左
+	t.visited[ry*t.width+rx] |= 1 << dir
+}
+
+// isJunction 判断格点是否为边界段的分割点
+// 周围有三个及以上不同区域，或两个区域呈对角分布时，边界在此处分叉
+func (t *borderTracer) isJunction(x, y int) bool {
+	tl := t.facetAt(x-1, y-1)
+	tr := t.facetAt(x, y-1)
+	bl := t.facetAt(x-1, y)
+	br := t.facetAt(x, y)
+
+	if tl == br && tr == bl && tl != tr {
+		return true
+	}
+
+	distinct := []int{tl}
+	for _, f := range []int{tr, bl, br} {
+		found := false
+		for _, d := range distinct {
+			if d == f {
+				found = true
+				break
+			}
+		}
+		if !found {
+			distinct = append(distinct, f)
+		}
+	}
+	return len(distinct) >= 3
+}
+
+// edgeKey 返回单位边与方向无关的唯一编号
+func (t *borderTracer) edgeKey(x, y, dir int) uint64 {
+	switch dir {
+	case DIR_LEFT:
+		x--
+	case DIR_UP:
+		y--
+	}
+	key := uint64(y*(t.width+1)+x) * 2
+	if dir == DIR_DOWN || dir == DIR_UP {
+		key++
+	}
+	return key
+}
+
+// tracedEdge 追踪过程中记录的有向单位边
+type tracedEdge struct {
+	x, y, dir int
+}
+
+// traceLoop 从指定的单位边出发，沿区域边界绕行一周
+func (t *borderTracer) traceLoop(facet, startX, startY, startDir int) {
+	edges := make([]tracedEdge, 0, 16)
+
+	x, y, dir := startX, startY, startDir
+	for {
+		edges = append(edges, tracedEdge{x, y, dir})
+		t.markVisited(x, y, dir)
+		x, y = step(x, y, dir)
+
+		// 优先右转，保证对角相接的像素不会被视为连通
+		for _, next := range []int{(dir + 1) % 4, dir, (dir + 3) % 4} {
+			if t.isBorder(facet, x, y, next) {
+				dir = next
+				break
+			}
+		}
+		if x == startX && y == startY && dir == startDir {
+			break
+		}
+	}
+
+	// 旋转边列表，使其从分割点开始
+	first := -1
+	for i, edge := range edges {
+		if t.isJunction(edge.x, edge.y) {
+			first = i
+			break
+		}
+	}
+
+	loop := &BorderLoop{Segments: make([]SegmentRef, 0)}
+	if first < 0 {
+		loop.Segments = append(loop.Segments, t.addSegment(facet, edges, true))
+	} else {
+		edges = append(edges[first:], edges[:first]...)
+		begin := 0
+		for i := 1; i <= len(edges); i++ {
+			if i == len(edges) || t.isJunction(edges[i].x, edges[i].y) {
+				loop.Segments = append(loop.Segments, t.addSegment(facet, edges[begin:i], false))
+				begin = i
+			}
+		}
+	}
+
+	loop.Hole = signedArea(t.result.LoopPoints(loop)) < 0
+	t.result.FacetLoops[facet] = append(t.result.FacetLoops[facet], loop)
+}
+
+// addSegment 登记一段边界，已由另一侧区域追踪过的边界直接反向引用
+func (t *borderTracer) addSegment(facet int, edges []tracedEdge, closed bool) SegmentRef {
+	minKey := t.edgeKey(edges[0].x, edges[0].y, edges[0].dir)
+	for _, edge := range edges[1:] {
+		if key := t.edgeKey(edge.x, edge.y, edge.dir); key < minKey {
+			minKey = key
+		}
+	}
+	if id, ok := t.index[minKey]; ok {
+		return SegmentRef{Segment: id, Reverse: true}
+	}
+
+	// 闭合边界从拐点开始，避免起点落在直线中间
+	if closed {
+		for i := range edges {
+			if edges[i].dir != edges[(i+len(edges)-1)%len(edges)].dir {
+				edges = append(edges[i:len(edges):len(edges)], edges[:i]...)
+				break
+			}
+		}
+	}
+
+	points := []Point{{edges[0].x, edges[0].y}}
+	for i := 1; i < len(edges); i++ {
+		if edges[i].dir != edges[i-1].dir {
+			points = append(points, Point{edges[i].x, edges[i].y})
+		}
+	}
+	if !closed {
+		last := edges[len(edges)-1]
+		endX, endY := step(last.x, last.y, last.dir)
+		points = append(points, Point{endX, endY})
+	}
+
+	_, _, lx, ly := sides(edges[0].x, edges[0].y, edges[0].dir)
+	segment := &BorderSegment{
+		ID:     len(t.result.Segments),
+		FacetA: facet,
+		FacetB: t.facetAt(lx, ly),
+		Points: points,
+		Closed: closed,
+	}
+	t.result.Segments = append(t.result.Segments, segment)
+	t.index[minKey] = segment.ID
+	return SegmentRef{Segment: segment.ID}
+}
+
+// SegmentPoints 返回引用的边界段的点，按引用方向排列
+func (br *BorderResult) SegmentPoints(ref SegmentRef) []Point {
+	points := br.Segments[ref.Segment].Points
+	if !ref.Reverse {
+		return points
+	}
+	reversed := make([]Point, len(points))
+	for i, p := range points {
+		reversed[len(points)-1-i] = p
+	}
+	return reversed
+}
+
+// LoopPoints 将边界环拼接为一条闭合折线，首点不在末尾重复
+func (br *BorderResult) LoopPoints(loop *BorderLoop) []Point {
+	points := make([]Point, 0)
+	for _, ref := range loop.Segments {
+		for _, p := range br.SegmentPoints(ref) {
+			if len(points) > 0 && points[len(points)-1] == p {
+				continue
+			}
+			points = append(points, p)
+		}
+	}
+	if len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+	return points
+}
+
+// signedArea 计算闭合折线的有向面积（y 轴向下时顺时针为正）
+func signedArea(points []Point) float64 {
+	area := 0
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += points[i].X*points[j].Y - points[j].X*points[i].Y
+	}
+	return float64(area) / 2
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTraceBordersInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		colorMap := blobColorMap(40, 30, seed)
+		fr := ReduceFacets(colorMap, BuildFacets(colorMap), FacetReduceOptions{MinArea: 4})
+		br := TraceBorders(fr)
+
+		// 每段边界由 FacetA 正向引用一次，由 FacetB 反向引用一次（图像边缘除外）
+		forward := make([]int, len(br.Segments))
+		reverse := make([]int, len(br.Segments))
+		for id, facet := range fr.Facets {
+			loops := br.FacetLoops[id]
+			if facet == nil {
+				if len(loops) != 0 {
+					t.Fatalf("seed %d: removed facet %d has %d loops", seed, id, len(loops))
+				}
+				continue
+			}
+			if len(loops) == 0 || loops[0].Hole {
+				t.Fatalf("seed %d: facet %d has no outer loop first", seed, id)
+			}
+
+			area := 0.0
+			for i, loop := range loops {
+				if i > 0 && !loop.Hole {
+					t.Fatalf("seed %d: facet %d has more than one outer loop", seed, id)
+				}
+				checkLoopClosed(t, br, loop)
+				for _, ref := range loop.Segments {
+					segment := br.Segments[ref.Segment]
+					if ref.Reverse {
+						reverse[ref.Segment]++
+						if segment.FacetB != id {
+							t.Fatalf("seed %d: facet %d reverses segment %d of %d|%d", seed, id, ref.Segment, segment.FacetA, segment.FacetB)
+						}
+					} else {
+						forward[ref.Segment]++
+						if segment.FacetA != id {
+							t.Fatalf("seed %d: facet %d uses segment %d of %d|%d", seed, id, ref.Segment, segment.FacetA, segment.FacetB)
+						}
+					}
+				}
+				area += signedArea(br.LoopPoints(loop))
+			}
+			// 外边界的面积减去孔洞的面积等于区域的像素数
+			if math.Abs(area-float64(facet.PointCount)) > 1e-9 {
+				t.Fatalf("seed %d: facet %d encloses %v, has %d pixels", seed, id, area, facet.PointCount)
+			}
+		}
+
+		for id, segment := range br.Segments {
+			wantReverse := 1
+			if segment.FacetB == NO_FACET {
+				wantReverse = 0
+			} else if !containsID(fr.Facets[segment.FacetA].NeighbourFacets, uint32(segment.FacetB)) {
+				t.Fatalf("seed %d: segment %d separates non-neighbours %d and %d", seed, id, segment.FacetA, segment.FacetB)
+			}
+			if forward[id] != 1 || reverse[id] != wantReverse {
+				t.Fatalf("seed %d: segment %d used %d times forward, %d reversed", seed, id, forward[id], reverse[id])
+			}
+			for i, p := range segment.Points {
+				if p.X < 0 || p.Y < 0 || p.X > int(br.Width) || p.Y > int(br.Height) {
+					t.Fatalf("seed %d: segment %d point %v outside image", seed, id, p)
+				}
+				if i > 0 && p.X != segment.Points[i-1].X && p.Y != segment.Points[i-1].Y {
+					t.Fatalf("seed %d: segment %d has a diagonal step %v -> %v", seed, id, segment.Points[i-1], p)
+				}
+			}
+		}
+	}
+}
+
+// checkLoopClosed 检查边界环中相邻边界段首尾相接，最后一段回到起点
+func checkLoopClosed(t *testing.T, br *BorderResult, loop *BorderLoop) {
+	t.Helper()
+	if len(loop.Segments) == 1 && br.Segments[loop.Segments[0].Segment].Closed {
+		return
+	}
+	for i, ref := range loop.Segments {
+		if br.Segments[ref.Segment].Closed {
+			t.Fatalf("closed segment %d inside a loop of %d segments", ref.Segment, len(loop.Segments))
+		}
+		points := br.SegmentPoints(ref)
+		next := br.SegmentPoints(loop.Segments[(i+1)%len(loop.Segments)])
+		if points[len(points)-1] != next[0] {
+			t.Fatalf("segment %d ends at %v, next starts at %v", ref.Segment, points[len(points)-1], next[0])
+		}
+	}
+}
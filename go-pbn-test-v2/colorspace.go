@@ -0,0 +1,269 @@
+package main
+
+import (
+	"math"
+)
+
+// 量化使用的颜色空间
+const (
+	COLOR_SPACE_RGB   = iota // sRGB，直接使用原始分量
+	COLOR_SPACE_LAB          // CIELAB (D65)
+	COLOR_SPACE_OKLAB        // OKLab
+)
+
+// 将感知颜色空间的分量线性映射到 0~255，三个分量使用相同的缩放比例以保持欧几里得距离的比例
+const (
+	LAB_SCALE    = 1.15  // CIELAB 缩放比例
+	LAB_OFFSET   = 111.0 // CIELAB a、b 分量的偏移
+	OKLAB_SCALE  = 250.0 // OKLab 缩放比例
+	OKLAB_OFFSET = 0.32  // OKLab a、b 分量的偏移
+)
+
+// D65 白点
+const (
+	WHITE_X = 0.95047
+	WHITE_Y = 1.0
+	WHITE_Z = 1.08883
+)
+
+// srgbToLinearTable sRGB 分量到线性分量的查找表
+var srgbToLinearTable = func() [256]float64 {
+	var table [256]float64
+	for i := 0; i < 256; i++ {
+		c := float64(i) / 255.0
+		if c <= 0.04045 {
+			table[i] = c / 12.92
+		} else {
+			table[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+// linearToSRGB 将线性分量转换为 0~255 的 sRGB 分量
+func linearToSRGB(c float64) uint8 {
+	if c <= 0.0031308 {
+		c *= 12.92
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	return clampToUint8(c * 255.0)
+}
+
+// clampToUint8 将浮点数四舍五入并限制在 0~255
+func clampToUint8(value float64) uint8 {
+	value = math.Round(value)
+	if value < 0 {
+		return 0
+	}
+	if value > 255 {
+		return 255
+	}
+	return uint8(value)
+}
+
+// labF CIELAB 转换中使用的非线性函数
+func labF(t float64) float64 {
+	if t > 216.0/24389.0 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27.0*t + 16.0) / 116.0
+}
+
+// labFInverse labF 的反函数
+func labFInverse(t float64) float64 {
+	if t3 := t * t * t; t3 > 216.0/24389.0 {
+		return t3
+	}
+	return (116.0*t - 16.0) / (24389.0 / 27.0)
+}
+
+// rgbToLab 将 sRGB 颜色转换为 CIELAB
+func rgbToLab(r, g, b uint8) [3]float64 {
+	lr, lg, lb := srgbToLinearTable[r], srgbToLinearTable[g], srgbToLinearTable[b]
+
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / WHITE_X
+	y := (0.2126729*lr + 0.7151522*lg + 0.0721750*lb) / WHITE_Y
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / WHITE_Z
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return [3]float64{
+		116.0*fy - 16.0,
+		500.0 * (fx - fy),
+		200.0 * (fy - fz),
+	}
+}
+
+// labToRGB 将 CIELAB 颜色转换为 sRGB
+func labToRGB(lab [3]float64) [3]uint8 {
+	fy := (lab[0] + 16.0) / 116.0
+	fx := fy + lab[1]/500.0
+	fz := fy - lab[2]/200.0
+
+	x := labFInverse(fx) * WHITE_X
+	y := labFInverse(fy) * WHITE_Y
+	z := labFInverse(fz) * WHITE_Z
+
+	return [3]uint8{
+		linearToSRGB(3.2404542*x - 1.5371385*y - 0.4985314*z),
+		linearToSRGB(-0.9692660*x + 1.8760108*y + 0.0415560*z),
+		linearToSRGB(0.0556434*x - 0.2040259*y + 1.0572252*z),
+	}
+}
+
+// rgbToOKLab 将 sRGB 颜色转换为 OKLab
+func rgbToOKLab(r, g, b uint8) [3]float64 {
+	lr, lg, lb := srgbToLinearTable[r], srgbToLinearTable[g], srgbToLinearTable[b]
+
+	l := math.Cbrt(0.4122214708*lr + 0.5363325363*lg + 0.0514459929*lb)
+	m := math.Cbrt(0.2119034982*lr + 0.6806995451*lg + 0.1073969566*lb)
+	s := math.Cbrt(0.0883024619*lr + 0.2817188376*lg + 0.6299787005*lb)
+
+	return [3]float64{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// okLabToRGB 将 OKLab 颜色转换为 sRGB
+func okLabToRGB(lab [3]float64) [3]uint8 {
+	l := lab[0] + 0.3963377774*lab[1] + 0.2158037573*lab[2]
+	m := lab[0] - 0.1055613458*lab[1] - 0.0638541728*lab[2]
+	s := lab[0] - 0.0894841775*lab[1] - 1.2914855480*lab[2]
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	return [3]uint8{
+		linearToSRGB(4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		linearToSRGB(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		linearToSRGB(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+	}
+}
+
+// toWorkingSpace 将 sRGB 颜色转换为指定颜色空间中缩放到 0~255 的坐标
+func toWorkingSpace(colorSpace int, r, g, b uint8) [3]float64 {
+	switch colorSpace {
+	case COLOR_SPACE_LAB:
+		lab := rgbToLab(r, g, b)
+		return [3]float64{
+			lab[0] * LAB_SCALE,
+			(lab[1] + LAB_OFFSET) * LAB_SCALE,
+			(lab[2] + LAB_OFFSET) * LAB_SCALE,
+		}
+	case COLOR_SPACE_OKLAB:
+		lab := rgbToOKLab(r, g, b)
+		return [3]float64{
+			lab[0] * OKLAB_SCALE,
+			(lab[1] + OKLAB_OFFSET) * OKLAB_SCALE,
+			(lab[2] + OKLAB_OFFSET) * OKLAB_SCALE,
+		}
+	default:
+		return [3]float64{float64(r), float64(g), float64(b)}
+	}
+}
+
+// fromWorkingSpace 将颜色空间中的缩放坐标转换回 sRGB
+func fromWorkingSpace(colorSpace int, c [3]float64) [3]uint8 {
+	switch colorSpace {
+	case COLOR_SPACE_LAB:
+		return labToRGB([3]float64{
+			c[0] / LAB_SCALE,
+			c[1]/LAB_SCALE - LAB_OFFSET,
+			c[2]/LAB_SCALE - LAB_OFFSET,
+		})
+	case COLOR_SPACE_OKLAB:
+		return okLabToRGB([3]float64{
+			c[0] / OKLAB_SCALE,
+			c[1]/OKLAB_SCALE - OKLAB_OFFSET,
+			c[2]/OKLAB_SCALE - OKLAB_OFFSET,
+		})
+	default:
+		return [3]uint8{clampToUint8(c[0]), clampToUint8(c[1]), clampToUint8(c[2])}
+	}
+}
+
+// deltaE2000 计算两个 CIELAB 颜色之间的 CIEDE2000 色差
+func deltaE2000(lab1, lab2 [3]float64) float64 {
+	l1, a1, b1 := lab1[0], lab1[1], lab1[2]
+	l2, a2, b2 := lab2[0], lab2[1], lab2[2]
+
+	c1 := math.Hypot(a1, b1)
+	c2 := math.Hypot(a2, b2)
+	cMean7 := math.Pow((c1+c2)/2, 7)
+	g := 0.5 * (1 - math.Sqrt(cMean7/(cMean7+math.Pow(25, 7))))
+
+	a1p := (1 + g) * a1
+	a2p := (1 + g) * a2
+	c1p := math.Hypot(a1p, b1)
+	c2p := math.Hypot(a2p, b2)
+	h1p := hueAngle(a1p, b1)
+	h2p := hueAngle(a2p, b2)
+
+	deltaLp := l2 - l1
+	deltaCp := c2p - c1p
+	deltahp := 0.0
+	if c1p*c2p != 0 {
+		deltahp = h2p - h1p
+		if deltahp > 180 {
+			deltahp -= 360
+		} else if deltahp < -180 {
+			deltahp += 360
+		}
+	}
+	deltaHp := 2 * math.Sqrt(c1p*c2p) * math.Sin(deltahp*math.Pi/360)
+
+	lMean := (l1 + l2) / 2
+	cMeanP := (c1p + c2p) / 2
+	hMeanP := h1p + h2p
+	if c1p*c2p != 0 {
+		if math.Abs(h1p-h2p) <= 180 {
+			hMeanP /= 2
+		} else if h1p+h2p < 360 {
+			hMeanP = (hMeanP + 360) / 2
+		} else {
+			hMeanP = (hMeanP - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos((hMeanP-30)*math.Pi/180) +
+		0.24*math.Cos(2*hMeanP*math.Pi/180) +
+		0.32*math.Cos((3*hMeanP+6)*math.Pi/180) -
+		0.20*math.Cos((4*hMeanP-63)*math.Pi/180)
+	deltaTheta := 30 * math.Exp(-((hMeanP-275)/25)*((hMeanP-275)/25))
+	cMeanP7 := math.Pow(cMeanP, 7)
+	rc := 2 * math.Sqrt(cMeanP7/(cMeanP7+math.Pow(25, 7)))
+	lOffset := (lMean - 50) * (lMean - 50)
+	sl := 1 + 0.015*lOffset/math.Sqrt(20+lOffset)
+	sc := 1 + 0.045*cMeanP
+	sh := 1 + 0.015*cMeanP*t
+	rt := -math.Sin(2*deltaTheta*math.Pi/180) * rc
+
+	dl := deltaLp / sl
+	dc := deltaCp / sc
+	dh := deltaHp / sh
+	return math.Sqrt(dl*dl + dc*dc + dh*dh + rt*dc*dh)
+}
+
+// hueAngle 返回 (a, b) 的色相角，范围 0~360 度
+func hueAngle(a, b float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// ParseColorSpace 根据名称返回颜色空间，未知名称返回 COLOR_SPACE_RGB
+func ParseColorSpace(name string) int {
+	switch name {
+	case "lab", "cielab":
+		return COLOR_SPACE_LAB
+	case "oklab":
+		return COLOR_SPACE_OKLAB
+	default:
+		return COLOR_SPACE_RGB
+	}
+}
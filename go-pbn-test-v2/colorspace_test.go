@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestSolidColorRoundTripsInPerceptualSpaces(t *testing.T) {
+	colors := [][4]uint8{{123, 45, 200, 255}, {250, 249, 3, 255}, {17, 130, 96, 255}}
+	for _, colorSpace := range []int{COLOR_SPACE_RGB, COLOR_SPACE_LAB, COLOR_SPACE_OKLAB} {
+		for _, color := range colors {
+			bmp := NewBitmap(4, 4)
+			for i := 0; i < len(bmp.Data); i += 4 {
+				copy(bmp.Data[i:i+4], color[:])
+			}
+			options := DefaultQuantizerOptions()
+			options.ColorSpace = colorSpace
+			palette := NewQuantizerWithOptions(bmp, 4, options).BuildPalette()
+			if len(palette) != 1 || palette[0] != color {
+				t.Errorf("color space %d: %v quantized to %v", colorSpace, color, palette)
+			}
+		}
+	}
+}
+
+func TestDeltaE2000Reference(t *testing.T) {
+	// Sharma, Wu, Dalal (2005) 的参考数据
+	pairs := []struct {
+		lab1, lab2 [3]float64
+		expected   float64
+	}{
+		{[3]float64{50, 2.6772, -79.7751}, [3]float64{50, 0, -82.7485}, 2.0425},
+		{[3]float64{50, -1, 2}, [3]float64{50, 0, 0}, 2.3669},
+		{[3]float64{50, 2.5, 0}, [3]float64{73, 25, -18}, 27.1492},
+		{[3]float64{2.0776, 0.0795, -1.1350}, [3]float64{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+	for _, pair := range pairs {
+		if d := deltaE2000(pair.lab1, pair.lab2); d < pair.expected-0.0001 || d > pair.expected+0.0001 {
+			t.Errorf("deltaE2000(%v, %v) = %.4f, want %.4f", pair.lab1, pair.lab2, d, pair.expected)
+		}
+	}
+}
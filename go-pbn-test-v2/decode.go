@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"image"
+
+	// 注册解码器，image.Decode 根据文件头自动识别格式
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// DecodeImage 解码 PNG/JPEG/GIF/BMP/TIFF/WebP 文件内容，返回 Bitmap 及识别出的格式名称
+// 动画 GIF 和 WebP 只解码第一帧
+func DecodeImage(data []byte) (*Bitmap, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return NewBitmapFromImage(img), format, nil
+}
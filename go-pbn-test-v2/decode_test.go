@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// 1x1 的 WebP 文件，golang.org/x/image 没有 WebP 编码器
+const (
+	WEBP_LOSSLESS = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="         // 透明黑色
+	WEBP_LOSSY    = "UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA" // 灰色
+)
+
+// decodeTestImage 创建一张 3x2 的调色板图像，各编码器都能无损保存其中的颜色
+func decodeTestImage() *image.Paletted {
+	palette := color.Palette{
+		color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 255, 0, 255}, color.NRGBA{0, 0, 255, 255},
+		color.NRGBA{255, 255, 255, 255}, color.NRGBA{0, 0, 0, 255}, color.NRGBA{128, 64, 32, 255},
+	}
+	img := image.NewPaletted(image.Rect(0, 0, 3, 2), palette)
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	return img
+}
+
+func TestDecodeImage(t *testing.T) {
+	src := decodeTestImage()
+	encoders := []struct {
+		format string
+		encode func(w io.Writer, img image.Image) error
+		exact  bool
+	}{
+		{"png", png.Encode, true},
+		{"gif", func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }, true},
+		{"bmp", bmp.Encode, true},
+		{"tiff", func(w io.Writer, img image.Image) error { return tiff.Encode(w, img, nil) }, true},
+		{"jpeg", func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, &jpeg.Options{Quality: 100}) }, false},
+	}
+	for _, encoder := range encoders {
+		var buf bytes.Buffer
+		if err := encoder.encode(&buf, src); err != nil {
+			t.Fatal(err)
+		}
+		bitmap, format, err := DecodeImage(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", encoder.format, err)
+		}
+		if format != encoder.format || bitmap.Width != 3 || bitmap.Height != 2 {
+			t.Fatalf("%s: decoded as %s, %dx%d", encoder.format, format, bitmap.Width, bitmap.Height)
+		}
+		if !encoder.exact {
+			continue
+		}
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 3; x++ {
+				if got, want := bitmap.At(x, y), color.NRGBAModel.Convert(src.At(x, y)); got != want {
+					t.Fatalf("%s: pixel (%d, %d) = %v, want %v", encoder.format, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeWebP(t *testing.T) {
+	for encoded, want := range map[string]color.NRGBA{
+		WEBP_LOSSLESS: {0, 0, 0, 0},
+		WEBP_LOSSY:    {128, 128, 128, 255},
+	} {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bitmap, format, err := DecodeImage(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "webp" || bitmap.Width != 1 || bitmap.Height != 1 || bitmap.At(0, 0) != want {
+			t.Fatalf("decoded %s %dx%d %v, want %v", format, bitmap.Width, bitmap.Height, bitmap.At(0, 0), want)
+		}
+	}
+}
+
+func TestDecodeImageUnknownFormat(t *testing.T) {
+	if _, _, err := DecodeImage([]byte("not an image")); err == nil {
+		t.Fatal("decoding garbage succeeded")
+	}
+}
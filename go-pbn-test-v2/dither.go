@@ -0,0 +1,392 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// 抖动模式
+const (
+	DITHER_NONE            = iota // 不抖动，逐像素映射到最接近的颜色
+	DITHER_FLOYD_STEINBERG        // Floyd–Steinberg 误差扩散
+	DITHER_JARVIS                 // Jarvis–Judice–Ninke 误差扩散
+	DITHER_SIERRA                 // Sierra 误差扩散
+	DITHER_ATKINSON               // Atkinson 误差扩散
+	DITHER_BAYER2                 // 2x2 Bayer 有序抖动
+	DITHER_BAYER4                 // 4x4 Bayer 有序抖动
+	DITHER_BAYER8                 // 8x8 Bayer 有序抖动
+	DITHER_BLUE_NOISE             // 蓝噪声阈值图有序抖动
+)
+
+// BLUE_NOISE_SIZE 蓝噪声阈值图的边长
+const BLUE_NOISE_SIZE = 64
+
+// DitherOptions 抖动选项
+type DitherOptions struct {
+	Mode       int     // 抖动模式
+	Strength   float64 // 抖动强度，0~1，1 表示扩散全部误差
+	Serpentine bool    // 是否使用蛇形扫描，奇数行从右向左处理
+}
+
+// DefaultDitherOptions 返回默认的抖动选项（不抖动）
+func DefaultDitherOptions() DitherOptions {
+	return DitherOptions{
+		Mode:       DITHER_NONE,
+		Strength:   1.0,
+		Serpentine: true,
+	}
+}
+
+// ditherWeight 误差扩散核中的一项
+type ditherWeight struct {
+	dx, dy int
+	weight float64
+}
+
+// ditherKernel 误差扩散核，权重已除以总和
+type ditherKernel []ditherWeight
+
+// newDitherKernel 根据权重和除数创建误差扩散核
+func newDitherKernel(divisor float64, weights ...ditherWeight) ditherKernel {
+	kernel := make(ditherKernel, len(weights))
+	for i, w := range weights {
+		kernel[i] = ditherWeight{w.dx, w.dy, w.weight / divisor}
+	}
+	return kernel
+}
+
+// 各误差扩散算法的扩散核
+var ditherKernels = map[int]ditherKernel{
+	DITHER_FLOYD_STEINBERG: newDitherKernel(16,
+		ditherWeight{1, 0, 7},
+		ditherWeight{-1, 1, 3}, ditherWeight{0, 1, 5}, ditherWeight{1, 1, 1},
+	),
+	DITHER_JARVIS: newDitherKernel(48,
+		ditherWeight{1, 0, 7}, ditherWeight{2, 0, 5},
+		ditherWeight{-2, 1, 3}, ditherWeight{-1, 1, 5}, ditherWeight{0, 1, 7}, ditherWeight{1, 1, 5}, ditherWeight{2, 1, 3},
+		ditherWeight{-2, 2, 1}, ditherWeight{-1, 2, 3}, ditherWeight{0, 2, 5}, ditherWeight{1, 2, 3}, ditherWeight{2, 2, 1},
+	),
+	DITHER_SIERRA: newDitherKernel(32,
+		ditherWeight{1, 0, 5}, ditherWeight{2, 0, 3},
+		ditherWeight{-2, 1, 2}, ditherWeight{-1, 1, 4}, ditherWeight{0, 1, 5}, ditherWeight{1, 1, 4}, ditherWeight{2, 1, 2},
+		ditherWeight{-1, 2, 2}, ditherWeight{0, 2, 3}, ditherWeight{1, 2, 2},
+	),
+	// Atkinson 只扩散 6/8 的误差
+	DITHER_ATKINSON: newDitherKernel(8,
+		ditherWeight{1, 0, 1}, ditherWeight{2, 0, 1},
+		ditherWeight{-1, 1, 1}, ditherWeight{0, 1, 1}, ditherWeight{1, 1, 1},
+		ditherWeight{0, 2, 1},
+	),
+}
+
+// ParseDitherMode 根据名称返回抖动模式，未知名称返回 DITHER_NONE
+func ParseDitherMode(name string) int {
+	switch name {
+	case "floyd-steinberg", "fs":
+		return DITHER_FLOYD_STEINBERG
+	case "jarvis", "jjn":
+		return DITHER_JARVIS
+	case "sierra":
+		return DITHER_SIERRA
+	case "atkinson":
+		return DITHER_ATKINSON
+	case "bayer2":
+		return DITHER_BAYER2
+	case "bayer4":
+		return DITHER_BAYER4
+	case "bayer8", "ordered":
+		return DITHER_BAYER8
+	case "blue-noise", "bluenoise":
+		return DITHER_BLUE_NOISE
+	default:
+		return DITHER_NONE
+	}
+}
+
+// mapPixelsDiffusion 使用误差扩散抖动将像素映射到调色板
+func (q *Quantizer) mapPixelsDiffusion(mappedIndices *Uint8Array2D, kernel ditherKernel) {
+	width := int(q.Bitmap.Width)
+	height := int(q.Bitmap.Height)
+	data := q.Bitmap.Data
+	strength := q.Dither.Strength
+
+	// 误差缓冲区，只需保留扩散核覆盖的行
+	rows := 1
+	for _, w := range kernel {
+		if w.dy+1 > rows {
+			rows = w.dy + 1
+		}
+	}
+	errors := make([][]float64, rows)
+	for i := range errors {
+		errors[i] = make([]float64, width*3)
+	}
+
+	for y := 0; y < height; y++ {
+		current := errors[y%rows]
+
+		// 蛇形扫描时奇数行从右向左处理，扩散核水平镜像
+		reverse := q.Dither.Serpentine && y%2 == 1
+		x, step, end := 0, 1, width
+		if reverse {
+			x, step, end = width-1, -1, -1
+		}
+
+		for ; x != end; x += step {
+			pos := y*width + x
+			i := pos * 4
+			var color [4]uint8
+			for c := 0; c < 3; c++ {
+				color[c] = clampToUint8(float64(data[i+c]) + current[x*3+c])
+			}
+			color[3] = data[i+3]
+
+			colorIndex := q.findClosestPaletteIndex(color)
+			mappedIndices.SetByIndex(pos, colorIndex)
+
+			// 透明像素不扩散误差
+			if int(colorIndex) == q.TransparentIndex {
+				continue
+			}
+
+			chosen := q.Palette[colorIndex]
+			for c := 0; c < 3; c++ {
+				diff := (float64(color[c]) - float64(chosen[c])) * strength
+				if diff == 0 {
+					continue
+				}
+				for _, w := range kernel {
+					dx := w.dx
+					if reverse {
+						dx = -dx
+					}
+					nx := x + dx
+					ny := y + w.dy
+					if nx < 0 || nx >= width || ny >= height {
+						continue
+					}
+					errors[ny%rows][nx*3+c] += diff * w.weight
+				}
+			}
+		}
+
+		// 当前行处理完毕后清零，供后续行复用
+		for i := range current {
+			current[i] = 0
+		}
+	}
+}
+
+// thresholdMap 有序抖动的阈值图，值为 [-0.5, 0.5) 范围内的偏移
+type thresholdMap struct {
+	size   int
+	values []float64
+}
+
+// at 返回指定像素位置的阈值，阈值图在图像上平铺
+func (tm *thresholdMap) at(x, y int) float64 {
+	return tm.values[(y%tm.size)*tm.size+x%tm.size]
+}
+
+// newThresholdMap 将排序值（0 ~ size²-1）归一化为阈值图
+func newThresholdMap(size int, ranks []int) *thresholdMap {
+	n := float64(size * size)
+	values := make([]float64, len(ranks))
+	for i, rank := range ranks {
+		values[i] = (float64(rank)+0.5)/n - 0.5
+	}
+	return &thresholdMap{size: size, values: values}
+}
+
+// bayerRanks 递归生成 size x size 的 Bayer 矩阵，size 必须为 2 的幂
+func bayerRanks(size int) []int {
+	if size == 1 {
+		return []int{0}
+	}
+	half := size / 2
+	sub := bayerRanks(half)
+	ranks := make([]int, size*size)
+	// 子矩阵按 0 3 / 2 1 的顺序偏移，即 2x2 Bayer 矩阵
+	offsets := [2][2]int{{0, 2}, {3, 1}}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			ranks[y*size+x] = 4*sub[(y%half)*half+x%half] + offsets[y/half][x/half]
+		}
+	}
+	return ranks
+}
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseMap  *thresholdMap
+)
+
+// getBlueNoiseMap 返回蓝噪声阈值图，首次调用时生成
+func getBlueNoiseMap() *thresholdMap {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMap = newThresholdMap(BLUE_NOISE_SIZE, voidAndClusterRanks(BLUE_NOISE_SIZE, 1.5))
+	})
+	return blueNoiseMap
+}
+
+// voidAndClusterRanks 使用 void-and-cluster 算法生成蓝噪声排序值
+// 使用固定的随机种子，保证每次生成的阈值图相同
+func voidAndClusterRanks(size int, sigma float64) []int {
+	n := size * size
+
+	// 环绕的高斯滤波核
+	kernel := make([]float64, n)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := math.Min(float64(x), float64(size-x))
+			dy := math.Min(float64(y), float64(size-y))
+			kernel[y*size+x] = math.Exp(-(dx*dx + dy*dy) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	update := func(pos int, sign float64) {
+		px, py := pos%size, pos/size
+		for y := 0; y < size; y++ {
+			ky := ((y-py)%size + size) % size
+			for x := 0; x < size; x++ {
+				kx := ((x-px)%size + size) % size
+				energy[y*size+x] += sign * kernel[ky*size+kx]
+			}
+		}
+	}
+	set := func(pos int, value bool) {
+		if pattern[pos] == value {
+			return
+		}
+		pattern[pos] = value
+		if value {
+			update(pos, 1)
+		} else {
+			update(pos, -1)
+		}
+	}
+	// tightestCluster 返回能量最高的已填充点，largestVoid 返回能量最低的空点
+	tightestCluster := func() int {
+		best := -1
+		for i := 0; i < n; i++ {
+			if pattern[i] && (best < 0 || energy[i] > energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	largestVoid := func() int {
+		best := -1
+		for i := 0; i < n; i++ {
+			if !pattern[i] && (best < 0 || energy[i] < energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	// 初始随机图案，并反复把最密集的点移到最空旷的位置
+	random := rand.New(rand.NewSource(1))
+	ones := n / 10
+	for _, pos := range random.Perm(n)[:ones] {
+		set(pos, true)
+	}
+	for {
+		cluster := tightestCluster()
+		set(cluster, false)
+		void := largestVoid()
+		if void == cluster {
+			set(cluster, true)
+			break
+		}
+		set(void, true)
+	}
+	initial := make([]bool, n)
+	copy(initial, pattern)
+
+	ranks := make([]int, n)
+
+	// 第一阶段：从初始图案中依次移除最密集的点
+	for rank := ones - 1; rank >= 0; rank-- {
+		cluster := tightestCluster()
+		set(cluster, false)
+		ranks[cluster] = rank
+	}
+
+	// 第二阶段：恢复初始图案后依次填充最空旷的位置
+	for i := 0; i < n; i++ {
+		set(i, initial[i])
+	}
+	for rank := ones; rank < n; rank++ {
+		void := largestVoid()
+		set(void, true)
+		ranks[void] = rank
+	}
+
+	return ranks
+}
+
+// orderedDitherMap 返回抖动模式对应的阈值图，非有序抖动返回 nil
+func orderedDitherMap(mode int) *thresholdMap {
+	switch mode {
+	case DITHER_BAYER2:
+		return newThresholdMap(2, bayerRanks(2))
+	case DITHER_BAYER4:
+		return newThresholdMap(4, bayerRanks(4))
+	case DITHER_BAYER8:
+		return newThresholdMap(8, bayerRanks(8))
+	case DITHER_BLUE_NOISE:
+		return getBlueNoiseMap()
+	default:
+		return nil
+	}
+}
+
+// paletteSpread 估计调色板颜色之间的平均间距，作为有序抖动的幅度
+func paletteSpread(palette [][4]uint8) float64 {
+	if len(palette) < 2 {
+		return 0
+	}
+	total := 0.0
+	for i, a := range palette {
+		nearest := uint32(math.MaxUint32)
+		for j, b := range palette {
+			if i == j {
+				continue
+			}
+			if d := colorDistanceSquared(a, b); d < nearest {
+				nearest = d
+			}
+		}
+		total += math.Sqrt(float64(nearest))
+	}
+	return total / float64(len(palette))
+}
+
+// mapPixelsOrdered 使用有序抖动将像素映射到调色板
+// 每个像素的结果只取决于其位置和颜色，分块处理时结果一致
+func (q *Quantizer) mapPixelsOrdered(mappedIndices *Uint8Array2D, tm *thresholdMap) {
+	width := int(q.Bitmap.Width)
+	height := int(q.Bitmap.Height)
+	data := q.Bitmap.Data
+	// 末尾保留的透明颜色不参与查找，也不计入幅度
+	spread := paletteSpread(q.searchPalette()) * q.Dither.Strength
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pos := y*width + x
+			i := pos * 4
+			offset := tm.at(x, y) * spread
+			color := [4]uint8{
+				clampToUint8(float64(data[i]) + offset),
+				clampToUint8(float64(data[i+1]) + offset),
+				clampToUint8(float64(data[i+2]) + offset),
+				data[i+3],
+			}
+			mappedIndices.SetByIndex(pos, q.findClosestPaletteIndex(color))
+		}
+	}
+}
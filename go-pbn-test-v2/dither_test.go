@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// grayBitmap 创建一张水平灰度渐变
+func grayBitmap(width, height uint32) *Bitmap {
+	bmp := NewBitmap(width, height)
+	for y := uint32(0); y < height; y++ {
+		for x := uint32(0); x < width; x++ {
+			pos := (y*width + x) * 4
+			v := uint8(x * 255 / (width - 1))
+			copy(bmp.Data[pos:], []uint8{v, v, v, 255})
+		}
+	}
+	return bmp
+}
+
+// ditherIndices 使用固定调色板和指定的抖动选项映射像素，返回颜色索引
+func ditherIndices(bmp *Bitmap, palette [][4]uint8, dither DitherOptions, threshold uint8) []uint8 {
+	options := DefaultQuantizerOptions()
+	options.Dither = dither
+	options.Alpha.Threshold = threshold
+	quantizer := NewQuantizerWithPalette(bmp, palette, options)
+	quantizer.BuildPalette()
+	return quantizer.MapPixels().MappedIndices.Data
+}
+
+var grayPalette = [][4]uint8{{0, 0, 0, 255}, {85, 85, 85, 255}, {170, 170, 170, 255}, {255, 255, 255, 255}}
+
+func TestBayerRanks(t *testing.T) {
+	if ranks := bayerRanks(2); !equalInts(ranks, []int{0, 2, 3, 1}) {
+		t.Fatalf("bayerRanks(2) = %v", ranks)
+	}
+	for _, size := range []int{2, 4, 8} {
+		checkPermutation(t, bayerRanks(size), size*size)
+	}
+}
+
+func TestBlueNoiseDeterministic(t *testing.T) {
+	first := voidAndClusterRanks(16, 1.5)
+	checkPermutation(t, first, 16*16)
+	if !equalInts(first, voidAndClusterRanks(16, 1.5)) {
+		t.Fatal("void-and-cluster ranks differ between runs")
+	}
+	if tm := getBlueNoiseMap(); tm.size != BLUE_NOISE_SIZE || len(tm.values) != BLUE_NOISE_SIZE*BLUE_NOISE_SIZE {
+		t.Fatalf("blue noise map %dx%d with %d values", tm.size, tm.size, len(tm.values))
+	}
+}
+
+func TestThresholdMapTiling(t *testing.T) {
+	for _, mode := range []int{DITHER_BAYER2, DITHER_BAYER4, DITHER_BAYER8, DITHER_BLUE_NOISE} {
+		tm := orderedDitherMap(mode)
+		sum := 0.0
+		for _, value := range tm.values {
+			if value < -0.5 || value >= 0.5 {
+				t.Fatalf("mode %d: threshold %v out of range", mode, value)
+			}
+			sum += value
+		}
+		if sum > 1e-9 || sum < -1e-9 {
+			t.Fatalf("mode %d: thresholds sum to %v, want 0", mode, sum)
+		}
+		for y := 0; y < tm.size; y++ {
+			for x := 0; x < tm.size; x++ {
+				if tm.at(x, y) != tm.at(x+tm.size, y) || tm.at(x, y) != tm.at(x, y+3*tm.size) {
+					t.Fatalf("mode %d: threshold map does not tile at (%d, %d)", mode, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestOrderedDither(t *testing.T) {
+	bmp := grayBitmap(70, 20)
+	plain := ditherIndices(bmp, grayPalette, DitherOptions{Mode: DITHER_NONE}, 0)
+	for _, mode := range []int{DITHER_BAYER2, DITHER_BAYER4, DITHER_BAYER8, DITHER_BLUE_NOISE} {
+		dither := DitherOptions{Mode: mode, Strength: 1}
+		first := ditherIndices(bmp, grayPalette, dither, 0)
+		if !bytes.Equal(first, ditherIndices(bmp, grayPalette, dither, 0)) {
+			t.Fatalf("mode %d: ordered dithering is not deterministic", mode)
+		}
+		if bytes.Equal(first, plain) {
+			t.Fatalf("mode %d: dithering did not change any pixel", mode)
+		}
+		if zero := ditherIndices(bmp, grayPalette, DitherOptions{Mode: mode}, 0); !bytes.Equal(zero, plain) {
+			t.Fatalf("mode %d: strength 0 differs from plain mapping", mode)
+		}
+	}
+}
+
+func TestOrderedDitherIgnoresTransparentEntry(t *testing.T) {
+	// 第一个像素透明时调色板末尾追加透明颜色，其余像素的抖动结果不应改变
+	opaque := grayBitmap(70, 20)
+	transparent := opaque.Clone()
+	transparent.Data[3] = 0
+
+	dither := DitherOptions{Mode: DITHER_BAYER8, Strength: 1}
+	want := ditherIndices(opaque, grayPalette, dither, 128)
+	got := ditherIndices(transparent, grayPalette, dither, 128)
+	if got[0] != uint8(len(grayPalette)) {
+		t.Fatalf("transparent pixel mapped to %d", got[0])
+	}
+	if !bytes.Equal(got[1:], want[1:]) {
+		t.Fatal("reserved transparent color changed the dither amplitude")
+	}
+}
+
+// checkPermutation 检查 ranks 是 0 ~ n-1 的一个排列
+func checkPermutation(t *testing.T, ranks []int, n int) {
+	t.Helper()
+	seen := make([]bool, n)
+	if len(ranks) != n {
+		t.Fatalf("%d ranks, want %d", len(ranks), n)
+	}
+	for _, rank := range ranks {
+		if rank < 0 || rank >= n || seen[rank] {
+			t.Fatalf("ranks %v are not a permutation", ranks)
+		}
+		seen[rank] = true
+	}
+}
+
+// equalInts 判断两个整数切片是否相同
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDitherKernels(t *testing.T) {
+	totals := map[int]float64{
+		DITHER_FLOYD_STEINBERG: 1,
+		DITHER_JARVIS:          1,
+		DITHER_SIERRA:          1,
+		DITHER_ATKINSON:        0.75,
+	}
+	for mode, want := range totals {
+		total := 0.0
+		for _, w := range ditherKernels[mode] {
+			// 误差只能扩散到尚未处理的像素
+			if w.dy < 0 || (w.dy == 0 && w.dx <= 0) {
+				t.Fatalf("mode %d: weight at (%d, %d) points backwards", mode, w.dx, w.dy)
+			}
+			total += w.weight
+		}
+		if total < want-1e-9 || total > want+1e-9 {
+			t.Fatalf("mode %d: weights sum to %v, want %v", mode, total, want)
+		}
+	}
+}
+
+func TestDiffusionDither(t *testing.T) {
+	// 只有黑白两种颜色时，扩散全部误差的算法应保持平均灰度
+	bmp := NewBitmap(64, 64)
+	for i := 0; i < len(bmp.Data); i += 4 {
+		copy(bmp.Data[i:], []uint8{100, 100, 100, 255})
+	}
+	blackWhite := [][4]uint8{{0, 0, 0, 255}, {255, 255, 255, 255}}
+	plain := ditherIndices(bmp, blackWhite, DitherOptions{Mode: DITHER_NONE}, 0)
+
+	for mode := range ditherKernels {
+		for _, serpentine := range []bool{false, true} {
+			indices := ditherIndices(bmp, blackWhite, DitherOptions{Mode: mode, Strength: 1, Serpentine: serpentine}, 0)
+			white := 0
+			for _, index := range indices {
+				white += int(index)
+			}
+			mean := float64(white) * 255 / float64(len(indices))
+			tolerance := 2.0
+			if mode == DITHER_ATKINSON {
+				// Atkinson 丢弃 1/4 的误差，平均灰度允许更大的偏差
+				tolerance = 10
+			}
+			if mean < 100-tolerance || mean > 100+tolerance {
+				t.Fatalf("mode %d, serpentine %v: mean gray %.1f, want 100", mode, serpentine, mean)
+			}
+
+			zero := ditherIndices(bmp, blackWhite, DitherOptions{Mode: mode, Serpentine: serpentine}, 0)
+			if !bytes.Equal(zero, plain) {
+				t.Fatalf("mode %d: strength 0 differs from plain mapping", mode)
+			}
+		}
+	}
+}
+
+func TestSerpentineScan(t *testing.T) {
+	// 第 0 行为调色板中的颜色，没有误差；蛇形扫描时第 1 行从右向左处理，
+	// 结果应与单独处理水平翻转后的该行再翻转回来相同
+	const width = 50
+	bmp := NewBitmap(width, 2)
+	row := NewBitmap(width, 1)
+	for x := 0; x < width; x++ {
+		v := uint8(x * 255 / (width - 1))
+		copy(bmp.Data[x*4:], []uint8{0, 0, 0, 255})
+		copy(bmp.Data[(width+x)*4:], []uint8{v, v, v, 255})
+		copy(row.Data[(width-1-x)*4:], []uint8{v, v, v, 255})
+	}
+
+	for mode := range ditherKernels {
+		dither := DitherOptions{Mode: mode, Strength: 1, Serpentine: true}
+		indices := ditherIndices(bmp, grayPalette, dither, 0)
+		mirrored := ditherIndices(row, grayPalette, dither, 0)
+		for x := 0; x < width; x++ {
+			if indices[width+x] != mirrored[width-1-x] {
+				t.Fatalf("mode %d: pixel %d is %d, mirrored scan gives %d", mode, x, indices[width+x], mirrored[width-1-x])
+			}
+		}
+
+		dither.Serpentine = false
+		if bytes.Equal(indices, ditherIndices(bmp, grayPalette, dither, 0)) {
+			t.Fatalf("mode %d: serpentine scan gives the same result as left-to-right", mode)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"sort"
+)
+
+// Uint32Array2D 储存每个像素对应的区域编号
+type Uint32Array2D struct {
+	Width  uint32
+	Height uint32
+	Data   []uint32
+}
+
+// NewUint32Array2D 创建一个新的 Uint32Array2D 实例
+func NewUint32Array2D(width, height uint32) *Uint32Array2D {
+	return &Uint32Array2D{
+		Width:  width,
+		Height: height,
+		Data:   make([]uint32, width*height),
+	}
+}
+
+// Set 设置指定位置的值
+func (ua *Uint32Array2D) Set(x, y uint32, value uint32) {
+	if x < ua.Width && y < ua.Height {
+		ua.Data[y*ua.Width+x] = value
+	}
+}
+
+// Get 获取指定位置的值
+func (ua *Uint32Array2D) Get(x, y uint32) uint32 {
+	if x < ua.Width && y < ua.Height {
+		return ua.Data[y*ua.Width+x]
+	}
+	return 0
+}
+
+// BoundingBox 区域的外接矩形（包含边界）
+type BoundingBox struct {
+	MinX, MinY uint32
+	MaxX, MaxY uint32
+}
+
+// Width 返回外接矩形的宽度
+func (bb BoundingBox) Width() uint32 {
+	return bb.MaxX - bb.MinX + 1
+}
+
+// Height 返回外接矩形的高度
+func (bb BoundingBox) Height() uint32 {
+	return bb.MaxY - bb.MinY + 1
+}
+
+// extend 扩展外接矩形以包含指定点
+func (bb *BoundingBox) extend(x, y uint32) {
+	if x < bb.MinX {
+		bb.MinX = x
+	}
+	if x > bb.MaxX {
+		bb.MaxX = x
+	}
+	if y < bb.MinY {
+		bb.MinY = y
+	}
+	if y > bb.MaxY {
+		bb.MaxY = y
+	}
+}
+
+// Facet 代表一个颜色相同且四连通的区域
+type Facet struct {
+	ID              uint32
+	ColorIndex      uint8       // 区域在调色板中的颜色索引
+	PointCount      int         // 区域包含的像素数
+	BBox            BoundingBox // 区域的外接矩形
+	NeighbourFacets []uint32    // 相邻区域的编号，按升序排列
+}
+
+// FacetResult 储存区域提取的结果
+type FacetResult struct {
+	Width    uint32
+	Height   uint32
+	Facets   []*Facet       // 索引即为区域编号，被删除的区域为 nil
+	FacetMap *Uint32Array2D // 储存每个像素所属的区域编号
+}
+
+// BuildFacets 对 ColorMap 进行泛洪填充，提取所有连通区域
+func BuildFacets(colorMap *ColorMap) *FacetResult {
+	width := colorMap.Width
+	height := colorMap.Height
+	indices := colorMap.MappedIndices.Data
+
+	result := &FacetResult{
+		Width:    width,
+		Height:   height,
+		Facets:   make([]*Facet, 0),
+		FacetMap: NewUint32Array2D(width, height),
+	}
+
+	visited := make([]bool, width*height)
+	stack := make([]uint32, 0, 1024)
+
+	for start := uint32(0); start < width*height; start++ {
+		if visited[start] {
+			continue
+		}
+
+		colorIndex := indices[start]
+		facet := &Facet{
+			ID:         uint32(len(result.Facets)),
+			ColorIndex: colorIndex,
+			BBox: BoundingBox{
+				MinX: start % width,
+				MinY: start / width,
+				MaxX: start % width,
+				MaxY: start / width,
+			},
+		}
+
+		// 使用显式栈进行泛洪填充，避免递归过深
+		visited[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			pos := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			x := pos % width
+			y := pos / width
+			result.FacetMap.Data[pos] = facet.ID
+			facet.PointCount++
+			facet.BBox.extend(x, y)
+
+			if x > 0 && !visited[pos-1] && indices[pos-1] == colorIndex {
+				visited[pos-1] = true
+				stack = append(stack, pos-1)
+			}
+			if x+1 < width && !visited[pos+1] && indices[pos+1] == colorIndex {
+				visited[pos+1] = true
+				stack = append(stack, pos+1)
+			}
+			if y > 0 && !visited[pos-width] && indices[pos-width] == colorIndex {
+				visited[pos-width] = true
+				stack = append(stack, pos-width)
+			}
+			if y+1 < height && !visited[pos+width] && indices[pos+width] == colorIndex {
+				visited[pos+width] = true
+				stack = append(stack, pos+width)
+			}
+		}
+
+		result.Facets = append(result.Facets, facet)
+	}
+
+	result.BuildNeighbours()
+	return result
+}
+
+// BuildNeighbours 根据 FacetMap 重新计算所有区域的相邻关系
+func (fr *FacetResult) BuildNeighbours() {
+	neighbours := make([]map[uint32]struct{}, len(fr.Facets))
+
+	link := func(a, b uint32) {
+		if neighbours[a] == nil {
+			neighbours[a] = make(map[uint32]struct{})
+		}
+		if neighbours[b] == nil {
+			neighbours[b] = make(map[uint32]struct{})
+		}
+		neighbours[a][b] = struct{}{}
+		neighbours[b][a] = struct{}{}
+	}
+
+	// 只需检查右侧和下方的像素，即可覆盖所有四连通的边
+	data := fr.FacetMap.Data
+	for y := uint32(0); y < fr.Height; y++ {
+		for x := uint32(0); x < fr.Width; x++ {
+			pos := y*fr.Width + x
+			if x+1 < fr.Width && data[pos] != data[pos+1] {
+				link(data[pos], data[pos+1])
+			}
+			if y+1 < fr.Height && data[pos] != data[pos+fr.Width] {
+				link(data[pos], data[pos+fr.Width])
+			}
+		}
+	}
+
+	for id, facet := range fr.Facets {
+		if facet == nil {
+			continue
+		}
+		list := make([]uint32, 0, len(neighbours[id]))
+		for neighbour := range neighbours[id] {
+			list = append(list, neighbour)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		facet.NeighbourFacets = list
+	}
+}
+
+// Count 返回有效区域的数量
+func (fr *FacetResult) Count() int {
+	count := 0
+	for _, facet := range fr.Facets {
+		if facet != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// RemapColors 按 remap（旧索引到新索引）更新区域的颜色索引，用于调色板重新排列之后
+func (fr *FacetResult) RemapColors(remap []uint8) {
+	for _, facet := range fr.Facets {
+		if facet != nil && int(facet.ColorIndex) < len(remap) {
+			facet.ColorIndex = remap[facet.ColorIndex]
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"sort"
+)
+
+// FacetReduceOptions 小区域清理选项
+type FacetReduceOptions struct {
+	MinArea   int // 像素数小于该值的区域会被合并到相邻区域
+	MaxFacets int // 区域数量上限，0 表示不限制
+}
+
+// ReduceFacets 反复清理小区域，将其像素并入颜色最接近的相邻区域
+// 会直接修改 colorMap.MappedIndices，并返回重新编号后的区域结果
+func ReduceFacets(colorMap *ColorMap, facetResult *FacetResult, options FacetReduceOptions) *FacetResult {
+	// 按面积清理，直到不存在小区域或无法继续合并
+	if options.MinArea > 1 {
+		for {
+			small := facetResult.sortedBySize(func(facet *Facet) bool {
+				return facet.PointCount < options.MinArea
+			})
+			if len(small) == 0 {
+				break
+			}
+
+			merged := 0
+			for _, facet := range small {
+				// 合并过程中区域可能已吸收了其它区域而不再是小区域
+				if facet.PointCount >= options.MinArea {
+					continue
+				}
+				if facetResult.mergeIntoClosestNeighbour(colorMap, facet) {
+					merged++
+				}
+			}
+			if merged == 0 {
+				break
+			}
+
+			// 重新提取区域，使合并后相邻的同色区域连成一片
+			facetResult = BuildFacets(colorMap)
+		}
+	}
+
+	// 按数量清理，从最小的区域开始合并
+	if options.MaxFacets > 0 {
+		for facetResult.Count() > options.MaxFacets {
+			count := facetResult.Count()
+			merged := 0
+			for _, facet := range facetResult.sortedBySize(nil) {
+				if count <= options.MaxFacets {
+					break
+				}
+				if facetResult.Facets[facet.ID] == nil {
+					continue
+				}
+				if facetResult.mergeIntoClosestNeighbour(colorMap, facet) {
+					count--
+					merged++
+				}
+			}
+			if merged == 0 {
+				break
+			}
+
+			facetResult = BuildFacets(colorMap)
+		}
+	}
+
+	return facetResult
+}
+
+// sortedBySize 返回满足条件的区域，按像素数升序排列
+func (fr *FacetResult) sortedBySize(filter func(facet *Facet) bool) []*Facet {
+	list := make([]*Facet, 0)
+	for _, facet := range fr.Facets {
+		if facet == nil {
+			continue
+		}
+		if filter != nil && !filter(facet) {
+			continue
+		}
+		list = append(list, facet)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].PointCount < list[j].PointCount
+	})
+	return list
+}
+
+// mergeIntoClosestNeighbour 将区域合并到调色板颜色最接近的相邻区域
+func (fr *FacetResult) mergeIntoClosestNeighbour(colorMap *ColorMap, facet *Facet) bool {
+	if len(facet.NeighbourFacets) == 0 {
+		return false
+	}
+
+	// 颜色距离相同时选择面积更大的相邻区域
+	var target *Facet
+	minDistance := uint32(0)
+	for _, neighbourID := range facet.NeighbourFacets {
+		neighbour := fr.Facets[neighbourID]
+		if neighbour == nil {
+			continue
+		}
+		distance := colorDistanceSquared(colorMap.Colors[facet.ColorIndex], colorMap.Colors[neighbour.ColorIndex])
+		if target == nil || distance < minDistance ||
+			(distance == minDistance && neighbour.PointCount > target.PointCount) {
+			target = neighbour
+			minDistance = distance
+		}
+	}
+	if target == nil {
+		return false
+	}
+
+	// 只需扫描被合并区域的外接矩形
+	for y := facet.BBox.MinY; y <= facet.BBox.MaxY; y++ {
+		for x := facet.BBox.MinX; x <= facet.BBox.MaxX; x++ {
+			pos := y*fr.Width + x
+			if fr.FacetMap.Data[pos] == facet.ID {
+				fr.FacetMap.Data[pos] = target.ID
+				colorMap.MappedIndices.Data[pos] = target.ColorIndex
+			}
+		}
+	}
+	target.PointCount += facet.PointCount
+	target.BBox.extend(facet.BBox.MinX, facet.BBox.MinY)
+	target.BBox.extend(facet.BBox.MaxX, facet.BBox.MaxY)
+
+	// 更新相邻关系：被合并区域的相邻区域改为与目标区域相邻
+	target.NeighbourFacets = removeSortedID(target.NeighbourFacets, facet.ID)
+	for _, neighbourID := range facet.NeighbourFacets {
+		if neighbourID == target.ID {
+			continue
+		}
+		neighbour := fr.Facets[neighbourID]
+		if neighbour == nil {
+			continue
+		}
+		neighbour.NeighbourFacets = removeSortedID(neighbour.NeighbourFacets, facet.ID)
+		neighbour.NeighbourFacets = insertSortedID(neighbour.NeighbourFacets, target.ID)
+		target.NeighbourFacets = insertSortedID(target.NeighbourFacets, neighbourID)
+	}
+
+	fr.Facets[facet.ID] = nil
+	return true
+}
+
+// insertSortedID 向有序编号列表中插入编号，已存在时不重复插入
+func insertSortedID(list []uint32, id uint32) []uint32 {
+	i := sort.Search(len(list), func(i int) bool { return list[i] >= id })
+	if i < len(list) && list[i] == id {
+		return list
+	}
+	list = append(list, 0)
+	copy(list[i+1:], list[i:])
+	list[i] = id
+	return list
+}
+
+// removeSortedID 从有序编号列表中删除编号
+func removeSortedID(list []uint32, id uint32) []uint32 {
+	i := sort.Search(len(list), func(i int) bool { return list[i] >= id })
+	if i < len(list) && list[i] == id {
+		return append(list[:i], list[i+1:]...)
+	}
+	return list
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// blobColorMap 创建一张由随机色块组成的 ColorMap，包含大小不一的区域和孔洞
+func blobColorMap(width, height uint32, seed int64) *ColorMap {
+	colors := [][4]uint8{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {250, 10, 10, 255}, {0, 0, 0, 0}}
+	colorMap := NewColorMap(width, height, colors)
+	rng := rand.New(rand.NewSource(seed))
+	data := colorMap.MappedIndices.Data
+	for y := uint32(0); y < height; y++ {
+		for x := uint32(0); x < width; x++ {
+			pos := y*width + x
+			switch r := rng.Intn(10); {
+			case r < 4 && x > 0:
+				data[pos] = data[pos-1]
+			case r < 8 && y > 0:
+				data[pos] = data[pos-width]
+			default:
+				data[pos] = uint8(rng.Intn(len(colors)))
+			}
+		}
+	}
+	return colorMap
+}
+
+// checkFacets 检查区域结果与 ColorMap 一致：像素归属、像素数、外接矩形、连通性和相邻关系
+func checkFacets(t *testing.T, colorMap *ColorMap, fr *FacetResult) {
+	t.Helper()
+	width, height := colorMap.Width, colorMap.Height
+	counts := make([]int, len(fr.Facets))
+	for y := uint32(0); y < height; y++ {
+		for x := uint32(0); x < width; x++ {
+			id := fr.FacetMap.Get(x, y)
+			facet := fr.Facets[id]
+			if facet == nil {
+				t.Fatalf("pixel (%d, %d) belongs to removed facet %d", x, y, id)
+			}
+			if facet.ColorIndex != colorMap.MappedIndices.Get(x, y) {
+				t.Fatalf("pixel (%d, %d): facet color %d, pixel color %d", x, y, facet.ColorIndex, colorMap.MappedIndices.Get(x, y))
+			}
+			if x < facet.BBox.MinX || x > facet.BBox.MaxX || y < facet.BBox.MinY || y > facet.BBox.MaxY {
+				t.Fatalf("pixel (%d, %d) outside bbox %+v of facet %d", x, y, facet.BBox, id)
+			}
+			counts[id]++
+
+			// 同色的相邻像素必须属于同一区域，不同区域必须互为邻居
+			for _, next := range [][2]uint32{{x + 1, y}, {x, y + 1}} {
+				if next[0] >= width || next[1] >= height {
+					continue
+				}
+				other := fr.FacetMap.Get(next[0], next[1])
+				sameColor := colorMap.MappedIndices.Get(next[0], next[1]) == facet.ColorIndex
+				if sameColor != (other == id) {
+					t.Fatalf("pixels (%d, %d) and %v: facets %d and %d, same color %v", x, y, next, id, other, sameColor)
+				}
+				if other != id && !containsID(facet.NeighbourFacets, other) {
+					t.Fatalf("facet %d does not list neighbour %d", id, other)
+				}
+			}
+		}
+	}
+
+	total := 0
+	for id, facet := range fr.Facets {
+		if facet == nil {
+			continue
+		}
+		if facet.ID != uint32(id) || facet.PointCount != counts[id] {
+			t.Fatalf("facet %d: ID %d, PointCount %d, counted %d", id, facet.ID, facet.PointCount, counts[id])
+		}
+		total += facet.PointCount
+		if !sort.SliceIsSorted(facet.NeighbourFacets, func(i, j int) bool { return facet.NeighbourFacets[i] < facet.NeighbourFacets[j] }) {
+			t.Fatalf("facet %d: neighbours %v not sorted", id, facet.NeighbourFacets)
+		}
+		for _, neighbour := range facet.NeighbourFacets {
+			if fr.Facets[neighbour] == nil || !containsID(fr.Facets[neighbour].NeighbourFacets, uint32(id)) {
+				t.Fatalf("facet %d lists %d, but not the other way round", id, neighbour)
+			}
+		}
+	}
+	if total != int(width*height) {
+		t.Fatalf("facets cover %d pixels, want %d", total, width*height)
+	}
+}
+
+// containsID 判断升序列表中是否包含 id
+func containsID(list []uint32, id uint32) bool {
+	i := sort.Search(len(list), func(i int) bool { return list[i] >= id })
+	return i < len(list) && list[i] == id
+}
+
+func TestBuildFacetsInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		colorMap := blobColorMap(40, 30, seed)
+		checkFacets(t, colorMap, BuildFacets(colorMap))
+	}
+}
+
+func TestReduceFacetsInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		colorMap := blobColorMap(40, 30, seed)
+		fr := ReduceFacets(colorMap, BuildFacets(colorMap), FacetReduceOptions{MinArea: 8})
+		checkFacets(t, colorMap, fr)
+		for _, facet := range fr.Facets {
+			if facet != nil && facet.PointCount < 8 {
+				t.Fatalf("seed %d: facet %d has %d pixels after reduction", seed, facet.ID, facet.PointCount)
+			}
+		}
+
+		fr = ReduceFacets(colorMap, fr, FacetReduceOptions{MaxFacets: 10})
+		checkFacets(t, colorMap, fr)
+		if fr.Count() > 10 {
+			t.Fatalf("seed %d: %d facets after reduction, want at most 10", seed, fr.Count())
+		}
+	}
+}
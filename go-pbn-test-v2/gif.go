@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+)
+
+// 动画帧的调色板模式
+const (
+	GIF_PALETTE_GLOBAL = iota // 所有帧共用一个调色板，直方图在所有帧上累计
+	GIF_PALETTE_LOCAL         // 每一帧单独量化，使用各自的调色板
+)
+
+// DEFAULT_GIF_DELAY 默认的帧间隔，单位为 1/100 秒
+const DEFAULT_GIF_DELAY = 10
+
+// GIFOptions 动画 GIF 的编码选项
+type GIFOptions struct {
+	Delay     int   // 默认的帧间隔，单位为 1/100 秒
+	Delays    []int // 每一帧的间隔，长度不足时其余帧使用 Delay
+	Disposal  byte  // 帧的处理方式，gif.DisposalNone / gif.DisposalBackground / gif.DisposalPrevious，0 表示不指定
+	LoopCount int   // 循环次数，0 表示无限循环，-1 表示只播放一次
+}
+
+// DefaultGIFOptions 返回默认的 GIF 编码选项
+func DefaultGIFOptions() GIFOptions {
+	return GIFOptions{
+		Delay:    DEFAULT_GIF_DELAY,
+		Disposal: gif.DisposalNone,
+	}
+}
+
+// EncodeGIF 将 ColorMap 编码为静态 GIF
+// GIF 只支持一个完全透明的颜色，半透明颜色按不透明输出
+func EncodeGIF(w io.Writer, colorMap *ColorMap) error {
+	return gif.Encode(w, colorMap.ToPaletted(), &gif.Options{NumColors: 256})
+}
+
+// AddFrame 将另一帧的像素加入直方图，用于多帧共用一个调色板
+// 需要在 BuildPalette 之前调用，帧的尺寸可以与创建 Quantizer 时的 Bitmap 不同
+func (q *Quantizer) AddFrame(bitmap *Bitmap) {
+	q.sample(bitmap.Data)
+}
+
+// QuantizeFrames 量化一组帧，返回与 frames 一一对应的 ColorMap
+// GIF_PALETTE_GLOBAL 时所有 ColorMap 共用同一个调色板
+func QuantizeFrames(frames []*Bitmap, colors int, options QuantizerOptions, mode int) []*ColorMap {
+	colorMaps := make([]*ColorMap, len(frames))
+	if len(frames) == 0 {
+		return colorMaps
+	}
+
+	if mode == GIF_PALETTE_LOCAL {
+		for i, frame := range frames {
+			quantizer := NewQuantizerWithOptions(frame, colors, options)
+			quantizer.BuildPalette()
+			colorMaps[i] = quantizer.MapPixels()
+		}
+		return colorMaps
+	}
+
+	quantizer := NewQuantizerWithOptions(frames[0], colors, options)
+	for _, frame := range frames[1:] {
+		quantizer.AddFrame(frame)
+	}
+	quantizer.BuildPalette()
+	for i, frame := range frames {
+		// MapPixels 基于 q.Bitmap 映射，抖动时会修改其中的数据，因此每帧使用副本
+		quantizer.Bitmap = frame.Clone()
+		colorMaps[i] = quantizer.MapPixels()
+	}
+	return colorMaps
+}
+
+// EncodeAnimatedGIF 将多个 ColorMap 编码为动画 GIF
+// 所有帧的调色板相同时只写入全局调色板，否则每帧写入局部调色板
+func EncodeAnimatedGIF(w io.Writer, frames []*ColorMap, options GIFOptions) error {
+	if len(frames) == 0 {
+		return errors.New("gif: no frames")
+	}
+
+	animation := &gif.GIF{
+		Image:     make([]*image.Paletted, len(frames)),
+		Delay:     make([]int, len(frames)),
+		Disposal:  make([]byte, len(frames)),
+		LoopCount: options.LoopCount,
+	}
+	shared := true
+	for i, frame := range frames {
+		animation.Image[i] = frame.ToPaletted()
+		animation.Delay[i] = options.Delay
+		if i < len(options.Delays) {
+			animation.Delay[i] = options.Delays[i]
+		}
+		animation.Disposal[i] = options.Disposal
+
+		bounds := animation.Image[i].Bounds()
+		if bounds.Dx() > animation.Config.Width {
+			animation.Config.Width = bounds.Dx()
+		}
+		if bounds.Dy() > animation.Config.Height {
+			animation.Config.Height = bounds.Dy()
+		}
+		if !samePalette(frame.Colors, frames[0].Colors) {
+			shared = false
+		}
+	}
+	if shared {
+		// 帧的调色板与全局调色板一致时，编码器不会再写入局部调色板
+		animation.Config.ColorModel = animation.Image[0].Palette
+	}
+
+	return gif.EncodeAll(w, animation)
+}
+
+// samePalette 判断两个调色板是否完全相同
+func samePalette(a, b [][4]uint8) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// GIFBytes 将 ColorMap 编码为静态 GIF 并返回字节
+func GIFBytes(colorMap *ColorMap) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeGIF(&buf, colorMap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AnimatedGIFBytes 将多个 ColorMap 编码为动画 GIF 并返回字节
+func AnimatedGIFBytes(frames []*ColorMap, options GIFOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeAnimatedGIF(&buf, frames, options); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseGIFPaletteMode 根据名称返回调色板模式，未知名称返回 GIF_PALETTE_GLOBAL
+func ParseGIFPaletteMode(name string) int {
+	switch name {
+	case "local":
+		return GIF_PALETTE_LOCAL
+	default:
+		return GIF_PALETTE_GLOBAL
+	}
+}
+
+// ParseGIFDisposal 根据名称返回帧的处理方式，未知名称返回 0（不指定）
+func ParseGIFDisposal(name string) byte {
+	switch name {
+	case "none", "keep":
+		return gif.DisposalNone
+	case "background":
+		return gif.DisposalBackground
+	case "previous":
+		return gif.DisposalPrevious
+	default:
+		return 0
+	}
+}
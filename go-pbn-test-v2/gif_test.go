@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// shiftedFrames 创建一组逐帧平移的渐变帧
+func shiftedFrames(count int) []*Bitmap {
+	frames := make([]*Bitmap, count)
+	for i := range frames {
+		frame := gradientBitmap(24, 12)
+		for p := 0; p < len(frame.Data); p += 4 {
+			frame.Data[p+1] += uint8(i * 40)
+		}
+		frames[i] = frame
+	}
+	return frames
+}
+
+// checkGIFFrame 检查解码后的帧与 ColorMap 的索引和 RGB 颜色一致
+func checkGIFFrame(t *testing.T, frame int, decoded *gif.GIF, colorMap *ColorMap) {
+	t.Helper()
+	img := decoded.Image[frame]
+	if img.Bounds() != colorMap.Bounds() || !bytes.Equal(img.Pix, colorMap.MappedIndices.Data) {
+		t.Fatalf("frame %d: indices differ after round trip", frame)
+	}
+	for i, color := range colorMap.Colors {
+		r, g, b, _ := img.Palette[i].RGBA()
+		if color[3] != 0 && [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)} != [3]uint8{color[0], color[1], color[2]} {
+			t.Fatalf("frame %d: color %d = %v, want %v", frame, i, img.Palette[i], color)
+		}
+	}
+}
+
+func TestGIFRoundTrip(t *testing.T) {
+	options := DefaultQuantizerOptions()
+	options.Alpha.Threshold = 128
+	quantizer := NewQuantizerWithOptions(gradientBitmap(24, 12), 16, options)
+	quantizer.BuildPalette()
+	colorMap := quantizer.MapPixels()
+
+	data, err := GIFBytes(colorMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Image) != 1 {
+		t.Fatalf("%d frames, want 1", len(decoded.Image))
+	}
+	checkGIFFrame(t, 0, decoded, colorMap)
+}
+
+func TestAnimatedGIFRoundTrip(t *testing.T) {
+	options := DefaultQuantizerOptions()
+	options.Alpha.Threshold = 128
+	gifOptions := DefaultGIFOptions()
+	gifOptions.Delay = 20
+	gifOptions.Delays = []int{5}
+	gifOptions.Disposal = gif.DisposalBackground
+
+	for _, mode := range []int{GIF_PALETTE_GLOBAL, GIF_PALETTE_LOCAL} {
+		colorMaps := QuantizeFrames(shiftedFrames(3), 16, options, mode)
+		data, err := AnimatedGIFBytes(colorMaps, gifOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(decoded.Image) != 3 {
+			t.Fatalf("mode %d: %d frames, want 3", mode, len(decoded.Image))
+		}
+		for i, want := range []int{5, 20, 20} {
+			if decoded.Delay[i] != want || decoded.Disposal[i] != gif.DisposalBackground {
+				t.Fatalf("mode %d: frame %d delay %d disposal %d", mode, i, decoded.Delay[i], decoded.Disposal[i])
+			}
+		}
+		// 共用调色板时只写入全局调色板
+		palette, _ := decoded.Config.ColorModel.(color.Palette)
+		if global := len(palette) > 0; global != (mode == GIF_PALETTE_GLOBAL) {
+			t.Fatalf("mode %d: global color table present = %v", mode, global)
+		}
+		for i, colorMap := range colorMaps {
+			checkGIFFrame(t, i, decoded, colorMap)
+		}
+	}
+}
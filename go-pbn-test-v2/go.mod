@@ -1,3 +1,5 @@
 module go-pbn
 
 go 1.23
+
+require golang.org/x/image v0.24.0
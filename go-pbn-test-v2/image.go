@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// 确保 Bitmap 和 ColorMap 实现了标准库的图像接口
+var (
+	_ draw.Image          = (*Bitmap)(nil)
+	_ image.PalettedImage = (*ColorMap)(nil)
+)
+
+// ColorModel 返回 Bitmap 的颜色模型，Data 中的颜色未预乘 Alpha，与 Canvas 的 ImageData 一致
+func (bmp *Bitmap) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+// Bounds 返回 Bitmap 的范围，左上角为 (0, 0)
+func (bmp *Bitmap) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(bmp.Width), int(bmp.Height))
+}
+
+// At 返回指定位置的颜色，超出范围时返回透明颜色
+func (bmp *Bitmap) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(bmp.Bounds())) {
+		return color.NRGBA{}
+	}
+	pos := (y*int(bmp.Width) + x) * 4
+	return color.NRGBA{R: bmp.Data[pos], G: bmp.Data[pos+1], B: bmp.Data[pos+2], A: bmp.Data[pos+3]}
+}
+
+// Set 设置指定位置的颜色，超出范围时忽略
+func (bmp *Bitmap) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(bmp.Bounds())) {
+		return
+	}
+	pos := (y*int(bmp.Width) + x) * 4
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	bmp.Data[pos] = nrgba.R
+	bmp.Data[pos+1] = nrgba.G
+	bmp.Data[pos+2] = nrgba.B
+	bmp.Data[pos+3] = nrgba.A
+}
+
+// NRGBA 返回与 Bitmap 共享像素数据的 image.NRGBA，不复制数据
+// 标准库的编码器和 draw.Draw 对 *image.NRGBA 有专门的快速路径
+func (bmp *Bitmap) NRGBA() *image.NRGBA {
+	return &image.NRGBA{
+		Pix:    bmp.Data,
+		Stride: int(bmp.Width) * 4,
+		Rect:   bmp.Bounds(),
+	}
+}
+
+// NewBitmapFromImage 从任意 image.Image 创建 Bitmap，左上角移动到 (0, 0)
+func NewBitmapFromImage(img image.Image) *Bitmap {
+	bounds := img.Bounds()
+	bmp := NewBitmap(uint32(bounds.Dx()), uint32(bounds.Dy()))
+
+	// 未预乘的 NRGBA 可以逐行复制，其余类型交给 draw.Draw 转换
+	if src, ok := img.(*image.NRGBA); ok {
+		rowBytes := bounds.Dx() * 4
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			start := src.PixOffset(bounds.Min.X, y)
+			copy(bmp.Data[(y-bounds.Min.Y)*rowBytes:], src.Pix[start:start+rowBytes])
+		}
+		return bmp
+	}
+	draw.Draw(bmp.NRGBA(), bmp.Bounds(), img, bounds.Min, draw.Src)
+	return bmp
+}
+
+// ColorModel 返回 ColorMap 的调色板
+func (cm *ColorMap) ColorModel() color.Model {
+	return cm.palette()
+}
+
+// Bounds 返回 ColorMap 的范围，左上角为 (0, 0)
+func (cm *ColorMap) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(cm.Width), int(cm.Height))
+}
+
+// At 返回指定位置的颜色，超出范围或索引不在调色板中时返回透明颜色
+func (cm *ColorMap) At(x, y int) color.Color {
+	if x < 0 || y < 0 {
+		return color.NRGBA{}
+	}
+	c, ok := cm.GetPixelColor(uint32(x), uint32(y))
+	if !ok {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]}
+}
+
+// ColorIndexAt 返回指定位置的颜色索引，超出范围时返回 0
+func (cm *ColorMap) ColorIndexAt(x, y int) uint8 {
+	if x < 0 || y < 0 {
+		return 0
+	}
+	index, _ := cm.GetPixelIndex(uint32(x), uint32(y))
+	return index
+}
+
+// palette 将调色板转换为 color.Palette
+func (cm *ColorMap) palette() color.Palette {
+	palette := make(color.Palette, len(cm.Colors))
+	for i, c := range cm.Colors {
+		palette[i] = color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]}
+	}
+	return palette
+}
+
+// NewColorMapFromImage 从调色板图像（如 GIF 或索引颜色 PNG 解码得到的 *image.Paletted）创建 ColorMap
+// 图像的颜色模型必须是不超过 256 种颜色的 color.Palette，否则返回错误
+func NewColorMapFromImage(img image.PalettedImage) (*ColorMap, error) {
+	palette, ok := img.ColorModel().(color.Palette)
+	if !ok {
+		return nil, errors.New("color model is not a palette")
+	}
+	if len(palette) > MAX_COLOR {
+		return nil, errors.New("palette has more than 256 colors")
+	}
+
+	colors := make([][4]uint8, len(palette))
+	for i, c := range palette {
+		nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+		colors[i] = [4]uint8{nrgba.R, nrgba.G, nrgba.B, nrgba.A}
+	}
+
+	bounds := img.Bounds()
+	colorMap := NewColorMap(uint32(bounds.Dx()), uint32(bounds.Dy()), colors)
+	if src, ok := img.(*image.Paletted); ok {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			start := src.PixOffset(bounds.Min.X, y)
+			copy(colorMap.MappedIndices.Data[(y-bounds.Min.Y)*bounds.Dx():], src.Pix[start:start+bounds.Dx()])
+		}
+		return colorMap, nil
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			colorMap.SetPixelIndex(uint32(x-bounds.Min.X), uint32(y-bounds.Min.Y), img.ColorIndexAt(x, y))
+		}
+	}
+	return colorMap, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestBitmapAtSet(t *testing.T) {
+	bmp := NewBitmap(4, 3)
+	colors := []color.Color{
+		color.NRGBA{10, 20, 30, 255},
+		color.NRGBA{200, 100, 50, 128},
+		color.RGBA{64, 32, 16, 128}, // 预乘颜色按未预乘保存
+		color.Gray{77},
+	}
+	for i, c := range colors {
+		bmp.Set(i, 1, c)
+	}
+	for i, c := range colors {
+		if got, want := bmp.At(i, 1), color.NRGBAModel.Convert(c); got != want {
+			t.Fatalf("At(%d, 1) = %v, want %v", i, got, want)
+		}
+	}
+	if got := bmp.At(1, 1).(color.NRGBA); got != (color.NRGBA{200, 100, 50, 128}) {
+		t.Fatalf("non-premultiplied color changed: %v", got)
+	}
+
+	// 超出范围时 At 返回透明颜色，Set 不修改数据
+	before := string(bmp.Data)
+	for _, p := range []image.Point{{-1, 0}, {4, 0}, {0, 3}, {0, -1}} {
+		bmp.Set(p.X, p.Y, color.White)
+		if got := bmp.At(p.X, p.Y); got != (color.NRGBA{}) {
+			t.Fatalf("At(%v) = %v outside bounds", p, got)
+		}
+	}
+	if string(bmp.Data) != before {
+		t.Fatal("Set outside bounds modified the bitmap")
+	}
+	if bmp.Bounds() != image.Rect(0, 0, 4, 3) || bmp.ColorModel() != color.NRGBAModel {
+		t.Fatalf("bounds %v, model %v", bmp.Bounds(), bmp.ColorModel())
+	}
+}
+
+func TestNewBitmapFromSubImage(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(-3, 5, 7, 12))
+	for y := nrgba.Rect.Min.Y; y < nrgba.Rect.Max.Y; y++ {
+		for x := nrgba.Rect.Min.X; x < nrgba.Rect.Max.X; x++ {
+			nrgba.SetNRGBA(x, y, color.NRGBA{uint8(x + 3), uint8(y), uint8(x * y), uint8(100 + x)})
+		}
+	}
+	rgba := image.NewRGBA(nrgba.Rect)
+	draw.Draw(rgba, rgba.Rect, nrgba, nrgba.Rect.Min, draw.Src)
+
+	// NRGBA 走逐行复制的快速路径，RGBA 走 draw.Draw
+	rect := image.Rect(-1, 7, 4, 10)
+	for _, src := range []image.Image{nrgba.SubImage(rect), rgba.SubImage(rect)} {
+		bmp := NewBitmapFromImage(src)
+		if bmp.Width != 5 || bmp.Height != 3 {
+			t.Fatalf("%T: bitmap is %dx%d", src, bmp.Width, bmp.Height)
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				want := color.NRGBAModel.Convert(src.At(rect.Min.X+x, rect.Min.Y+y)).(color.NRGBA)
+				got := bmp.At(x, y).(color.NRGBA)
+				// 预乘颜色转换回未预乘时有舍入误差
+				if !closeNRGBA(got, want, 2) {
+					t.Fatalf("%T: pixel (%d, %d) = %v, want %v", src, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestColorMapPalettedImage(t *testing.T) {
+	colors := [][4]uint8{{255, 0, 0, 255}, {0, 255, 0, 128}, {0, 0, 0, 0}}
+	colorMap := NewColorMap(3, 2, colors)
+	copy(colorMap.MappedIndices.Data, []uint8{0, 1, 2, 2, 1, 7})
+
+	var img image.PalettedImage = colorMap
+	palette, ok := img.ColorModel().(color.Palette)
+	if !ok || len(palette) != len(colors) {
+		t.Fatalf("color model %v", img.ColorModel())
+	}
+	for i, c := range colors {
+		if palette[i] != (color.NRGBA{c[0], c[1], c[2], c[3]}) {
+			t.Fatalf("palette[%d] = %v, want %v", i, palette[i], c)
+		}
+	}
+	if img.ColorIndexAt(1, 1) != 1 || img.At(1, 0) != palette[1] {
+		t.Fatalf("ColorIndexAt(1, 1) = %d, At(1, 0) = %v", img.ColorIndexAt(1, 1), img.At(1, 0))
+	}
+	// 索引不在调色板中或超出范围时返回透明颜色
+	if img.At(2, 1) != (color.NRGBA{}) || img.At(-1, 0) != (color.NRGBA{}) || img.ColorIndexAt(5, 5) != 0 {
+		t.Fatalf("At(2, 1) = %v, At(-1, 0) = %v", img.At(2, 1), img.At(-1, 0))
+	}
+
+	// 从位于非零原点的子图像恢复 ColorMap
+	paletted := image.NewPaletted(image.Rect(2, 3, 8, 9), palette)
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(i % len(colors))
+	}
+	sub := paletted.SubImage(image.Rect(3, 4, 6, 6)).(*image.Paletted)
+	decoded, err := NewColorMapFromImage(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Width != 3 || decoded.Height != 2 || !samePalette(decoded.Colors, colors) {
+		t.Fatalf("color map %dx%d, palette %v", decoded.Width, decoded.Height, decoded.Colors)
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			if got, want := decoded.ColorIndexAt(x, y), sub.ColorIndexAt(3+x, 4+y); got != want {
+				t.Fatalf("index at (%d, %d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+	if _, err := NewColorMapFromImage(image.NewPaletted(image.Rect(0, 0, 1, 1), make(color.Palette, 300))); err == nil {
+		t.Fatal("palette with 300 colors accepted")
+	}
+}
+
+// closeNRGBA 判断两个颜色的每个分量之差不超过 tolerance
+func closeNRGBA(a, b color.NRGBA, tolerance int) bool {
+	for _, d := range []int{int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B), int(a.A) - int(b.A)} {
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// ToPaletted 将 ColorMap 转换为 image.Paletted，像素数据与 MappedIndices 共享，不复制
+func (cm *ColorMap) ToPaletted() *image.Paletted {
+	palette := cm.palette()
+	if len(palette) == 0 {
+		palette = append(palette, color.NRGBA{A: 255})
+	}
+
+	return &image.Paletted{
+		Pix:     cm.MappedIndices.Data,
+		Stride:  int(cm.Width),
+		Rect:    image.Rect(0, 0, int(cm.Width), int(cm.Height)),
+		Palette: palette,
+	}
+}
+
+// EncodeIndexedPNG 将 ColorMap 编码为索引颜色（color type 3）的 PNG
+// 调色板写入 PLTE 块，存在半透明颜色时写入 tRNS 块，索引与 ColorMap 中的颜色索引完全一致
+func EncodeIndexedPNG(w io.Writer, colorMap *ColorMap) error {
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(w, colorMap.ToPaletted())
+}
+
+// IndexedPNGBytes 将 ColorMap 编码为索引颜色 PNG 并返回字节
+func IndexedPNGBytes(colorMap *ColorMap) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeIndexedPNG(&buf, colorMap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
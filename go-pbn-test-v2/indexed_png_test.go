@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestIndexedPNGRoundTrip(t *testing.T) {
+	colors := [][4]uint8{{255, 0, 0, 255}, {0, 128, 255, 128}, {10, 20, 30, 255}, {0, 0, 0, 0}}
+	colorMap := NewColorMap(13, 7, colors)
+	for i := range colorMap.MappedIndices.Data {
+		colorMap.MappedIndices.Data[i] = uint8((i * 7 / 3) % len(colors))
+	}
+
+	data, err := IndexedPNGBytes(colorMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		t.Fatalf("decoded %T, want *image.Paletted", img)
+	}
+
+	decoded, err := NewColorMapFromImage(paletted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePalette(decoded.Colors, colors) {
+		t.Fatalf("palette %v, want %v", decoded.Colors, colors)
+	}
+	if decoded.Width != colorMap.Width || decoded.Height != colorMap.Height ||
+		!bytes.Equal(decoded.MappedIndices.Data, colorMap.MappedIndices.Data) {
+		t.Fatalf("indices differ after round trip")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// 标注字形的近似尺寸，以字号为单位
+const (
+	LABEL_CHAR_WIDTH  = 0.6 // 单个数字的宽度
+	LABEL_LINE_HEIGHT = 1.0 // 行高
+)
+
+// LabelOptions 标注放置选项
+type LabelOptions struct {
+	MinFontSize float64 // 小于该字号时认为区域放不下编号
+	MaxFontSize float64 // 字号上限，0 表示不限制
+}
+
+// FacetLabel 区域编号的放置位置
+type FacetLabel struct {
+	FacetID  uint32
+	Text     string
+	X, Y     float64 // 编号中心点，即区域内距离边界最远的点
+	Radius   float64 // 中心点到区域边界的距离
+	FontSize float64 // 编号能容纳的最大字号
+	Fits     bool    // 字号是否不小于最小字号
+}
+
+// PlaceLabels 为每个区域寻找距离边界最远的内部点（不可达极点）并计算可容纳的字号
+// 返回的切片以区域编号为索引，已删除的区域为 nil
+func PlaceLabels(facetResult *FacetResult, options LabelOptions) []*FacetLabel {
+	labels := make([]*FacetLabel, len(facetResult.Facets))
+	for id, facet := range facetResult.Facets {
+		if facet == nil {
+			continue
+		}
+
+		x, y, radius := facetResult.poleOfInaccessibility(facet)
+		text := strconv.Itoa(int(facet.ColorIndex))
+		fontSize := fitFontSize(text, radius)
+		if options.MaxFontSize > 0 && fontSize > options.MaxFontSize {
+			fontSize = options.MaxFontSize
+		}
+
+		labels[id] = &FacetLabel{
+			FacetID:  facet.ID,
+			Text:     text,
+			X:        x,
+			Y:        y,
+			Radius:   radius,
+			FontSize: fontSize,
+			Fits:     fontSize >= options.MinFontSize,
+		}
+	}
+	return labels
+}
+
+// fitFontSize 计算半径为 radius 的圆内能容纳的文字最大字号
+func fitFontSize(text string, radius float64) float64 {
+	// 文字外接矩形的半对角线不超过半径
+	halfWidth := float64(len(text)) * LABEL_CHAR_WIDTH / 2
+	halfHeight := LABEL_LINE_HEIGHT / 2
+	return radius / math.Sqrt(halfWidth*halfWidth+halfHeight*halfHeight)
+}
+
+// poleOfInaccessibility 通过距离变换找到区域内距离边界最远的像素
+// 返回像素中心坐标及其到区域边界的距离
+func (fr *FacetResult) poleOfInaccessibility(facet *Facet) (float64, float64, float64) {
+	// 在外接矩形四周各留出一个像素，作为区域外部
+	w := int(facet.BBox.Width()) + 2
+	h := int(facet.BBox.Height()) + 2
+	dist := make([]float64, w*h)
+
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			px := facet.BBox.MinX + uint32(x-1)
+			py := facet.BBox.MinY + uint32(y-1)
+			if fr.FacetMap.Get(px, py) == facet.ID {
+				dist[y*w+x] = math.MaxFloat64
+			}
+		}
+	}
+
+	// 两遍扫描的倒角距离变换，正交步长为 1，对角步长为 √2
+	relax := func(pos, from int, cost float64) {
+		if d := dist[from] + cost; d < dist[pos] {
+			dist[pos] = d
+		}
+	}
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			pos := y*w + x
+			if dist[pos] == 0 {
+				continue
+			}
+			relax(pos, pos-1, 1)
+			relax(pos, pos-w, 1)
+			relax(pos, pos-w-1, math.Sqrt2)
+			relax(pos, pos-w+1, math.Sqrt2)
+		}
+	}
+	bestPos := -1
+	for y := h - 2; y >= 1; y-- {
+		for x := w - 2; x >= 1; x-- {
+			pos := y*w + x
+			if dist[pos] == 0 {
+				continue
+			}
+			relax(pos, pos+1, 1)
+			relax(pos, pos+w, 1)
+			relax(pos, pos+w+1, math.Sqrt2)
+			relax(pos, pos+w-1, math.Sqrt2)
+
+			if bestPos < 0 || dist[pos] > dist[bestPos] {
+				bestPos = pos
+			}
+		}
+	}
+
+	if bestPos < 0 {
+		cx := float64(facet.BBox.MinX) + float64(facet.BBox.Width())/2
+		cy := float64(facet.BBox.MinY) + float64(facet.BBox.Height())/2
+		return cx, cy, 0
+	}
+
+	x := float64(facet.BBox.MinX) + float64(bestPos%w-1) + 0.5
+	y := float64(facet.BBox.MinY) + float64(bestPos/w-1) + 0.5
+	return x, y, exactBorderDistance(dist, w, h, bestPos)
+}
+
+// exactBorderDistance 计算像素中心到最近的区域外像素方块的欧几里得距离
+// 倒角距离在非正交方向上偏大，直接用作半径会使编号超出区域，因此在极点附近重新精确计算
+func exactBorderDistance(dist []float64, w, h, pos int) float64 {
+	// 倒角距离不小于到最近区域外像素中心的切比雪夫距离，更远的像素不会更近
+	reach := int(math.Ceil(dist[pos]))
+	cx, cy := pos%w, pos/w
+	best := dist[pos] - 0.5
+	for y := max(cy-reach, 0); y <= min(cy+reach, h-1); y++ {
+		for x := max(cx-reach, 0); x <= min(cx+reach, w-1); x++ {
+			if dist[y*w+x] != 0 {
+				continue
+			}
+			dx := math.Max(math.Abs(float64(x-cx))-0.5, 0)
+			dy := math.Max(math.Abs(float64(y-cy))-0.5, 0)
+			best = math.Min(best, math.Hypot(dx, dy))
+		}
+	}
+	return best
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// borderDistance 通过穷举计算点 (x, y) 到区域外最近像素的距离，图像外部视为区域外
+func borderDistance(fr *FacetResult, facet *Facet, x, y float64) float64 {
+	inside := func(px, py int) bool {
+		if px < int(facet.BBox.MinX) || px > int(facet.BBox.MaxX) || py < int(facet.BBox.MinY) || py > int(facet.BBox.MaxY) {
+			return false
+		}
+		return fr.FacetMap.Get(uint32(px), uint32(py)) == facet.ID
+	}
+	best := math.Inf(1)
+	for py := int(facet.BBox.MinY) - 1; py <= int(facet.BBox.MaxY)+1; py++ {
+		for px := int(facet.BBox.MinX) - 1; px <= int(facet.BBox.MaxX)+1; px++ {
+			if inside(px, py) {
+				continue
+			}
+			// 点到像素方块的最近距离
+			dx := math.Max(math.Max(float64(px)-x, x-float64(px+1)), 0)
+			dy := math.Max(math.Max(float64(py)-y, y-float64(py+1)), 0)
+			best = math.Min(best, math.Hypot(dx, dy))
+		}
+	}
+	return best
+}
+
+// checkLabels 检查每个编号位于所属区域内，且编号的外接矩形不超出区域边界
+func checkLabels(t *testing.T, fr *FacetResult, labels []*FacetLabel, options LabelOptions) {
+	t.Helper()
+	if len(labels) != len(fr.Facets) {
+		t.Fatalf("%d labels for %d facets", len(labels), len(fr.Facets))
+	}
+	for id, facet := range fr.Facets {
+		label := labels[id]
+		if (facet == nil) != (label == nil) {
+			t.Fatalf("facet %d: facet %v, label %v", id, facet, label)
+		}
+		if facet == nil {
+			continue
+		}
+		if label.FacetID != facet.ID || label.Text != strconv.Itoa(int(facet.ColorIndex)) {
+			t.Fatalf("facet %d: label %+v", id, label)
+		}
+
+		px, py := uint32(math.Floor(label.X)), uint32(math.Floor(label.Y))
+		if fr.FacetMap.Get(px, py) != facet.ID {
+			t.Fatalf("facet %d: label (%v, %v) lies in facet %d", id, label.X, label.Y, fr.FacetMap.Get(px, py))
+		}
+
+		distance := borderDistance(fr, facet, label.X, label.Y)
+		if label.Radius <= 0 || label.Radius > distance+1e-9 {
+			t.Fatalf("facet %d: radius %v, distance to border %v", id, label.Radius, distance)
+		}
+		halfWidth := float64(len(label.Text)) * LABEL_CHAR_WIDTH / 2 * label.FontSize
+		halfHeight := LABEL_LINE_HEIGHT / 2 * label.FontSize
+		if math.Hypot(halfWidth, halfHeight) > distance+1e-9 {
+			t.Fatalf("facet %d: font size %v does not fit distance %v", id, label.FontSize, distance)
+		}
+		if options.MaxFontSize > 0 && label.FontSize > options.MaxFontSize {
+			t.Fatalf("facet %d: font size %v above maximum %v", id, label.FontSize, options.MaxFontSize)
+		}
+		if label.Fits != (label.FontSize >= options.MinFontSize) {
+			t.Fatalf("facet %d: fits %v with font size %v", id, label.Fits, label.FontSize)
+		}
+	}
+}
+
+func TestPlaceLabelsInsideFacets(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		colorMap := blobColorMap(48, 40, seed)
+		fr := BuildFacets(colorMap)
+		options := LabelOptions{MinFontSize: 1}
+		checkLabels(t, fr, PlaceLabels(fr, options), options)
+
+		fr = ReduceFacets(colorMap, fr, FacetReduceOptions{MinArea: 12})
+		options = LabelOptions{MinFontSize: 2, MaxFontSize: 3}
+		checkLabels(t, fr, PlaceLabels(fr, options), options)
+	}
+}
+
+func TestPlaceLabelsThinFacets(t *testing.T) {
+	// 左侧是 20x20 的方块，右侧是一像素宽的竖线和对角线
+	colors := [][4]uint8{{255, 255, 255, 255}, {255, 0, 0, 255}, {0, 0, 255, 255}, {0, 255, 0, 255}}
+	colorMap := NewColorMap(32, 20, colors)
+	for y := uint32(0); y < 20; y++ {
+		for x := uint32(0); x < 32; x++ {
+			switch {
+			case x < 20:
+				colorMap.MappedIndices.Set(x, y, 1)
+			case x == 22:
+				colorMap.MappedIndices.Set(x, y, 2)
+			case x == 24+y%8 && y < 8:
+				colorMap.MappedIndices.Set(x, y, 3)
+			}
+		}
+	}
+	fr := BuildFacets(colorMap)
+	options := LabelOptions{MinFontSize: 4}
+	labels := PlaceLabels(fr, options)
+	checkLabels(t, fr, labels, options)
+
+	for id, facet := range fr.Facets {
+		if facet == nil {
+			continue
+		}
+		label := labels[id]
+		switch facet.ColorIndex {
+		case 1:
+			if !label.Fits || label.Radius < 9 {
+				t.Fatalf("square facet: %+v", label)
+			}
+		case 2, 3:
+			if label.Fits || label.Radius > 0.5+1e-9 {
+				t.Fatalf("thin facet with color %d: %+v", facet.ColorIndex, label)
+			}
+		}
+	}
+}
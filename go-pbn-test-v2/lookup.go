@@ -0,0 +1,253 @@
+package main
+
+import (
+	"sort"
+)
+
+const (
+	LOOKUP_CUBE_BITS  = SIGNIFICANT_BITS // 颜色立方体缓存使用的有效位数，与默认的直方图立方体划分一致
+	LOOKUP_CACHE_BITS = 16               // k-d 树查找结果缓存的大小（2 的幂）
+)
+
+// kdNode k-d 树的节点
+type kdNode struct {
+	index       int // 调色板索引
+	axis        int // 分割维度
+	left, right int // 子节点在 nodes 中的位置，-1 表示没有
+}
+
+// kdTree 调色板颜色的 k-d 树，用于精确的最近颜色查找
+type kdTree struct {
+	points [][]float64
+	nodes  []kdNode
+	root   int
+}
+
+// newKDTree 根据调色板坐标构建 k-d 树
+func newKDTree(points [][]float64) *kdTree {
+	tree := &kdTree{
+		points: points,
+		nodes:  make([]kdNode, 0, len(points)),
+	}
+	indices := make([]int, len(points))
+	for i := range indices {
+		indices[i] = i
+	}
+	tree.root = tree.build(indices, 0)
+	return tree
+}
+
+// build 递归构建子树，返回子树根节点的位置
+func (t *kdTree) build(indices []int, depth int) int {
+	if len(indices) == 0 {
+		return -1
+	}
+	axis := depth % len(t.points[indices[0]])
+	sort.Slice(indices, func(i, j int) bool {
+		return t.points[indices[i]][axis] < t.points[indices[j]][axis]
+	})
+	median := len(indices) / 2
+
+	pos := len(t.nodes)
+	t.nodes = append(t.nodes, kdNode{index: indices[median], axis: axis})
+	left := t.build(indices[:median], depth+1)
+	right := t.build(indices[median+1:], depth+1)
+	t.nodes[pos].left = left
+	t.nodes[pos].right = right
+	return pos
+}
+
+// nearest 查找与 target 距离最小的点
+// 距离相同时返回索引最小的点，与线性扫描的结果一致
+func (t *kdTree) nearest(target []float64) int {
+	best := -1
+	bestDistance := 0.0
+	t.search(t.root, target, &best, &bestDistance)
+	return best
+}
+
+// search 递归搜索子树
+func (t *kdTree) search(pos int, target []float64, best *int, bestDistance *float64) {
+	if pos < 0 {
+		return
+	}
+	node := t.nodes[pos]
+	point := t.points[node.index]
+
+	distance := 0.0
+	for i := range target {
+		d := target[i] - point[i]
+		distance += d * d
+	}
+	if *best < 0 || distance < *bestDistance || (distance == *bestDistance && node.index < *best) {
+		*best = node.index
+		*bestDistance = distance
+	}
+
+	diff := target[node.axis] - point[node.axis]
+	near, far := node.left, node.right
+	if diff > 0 {
+		near, far = far, near
+	}
+	t.search(near, target, best, bestDistance)
+	// 距离相等时也要搜索另一侧，以便找到索引更小的点
+	if diff*diff <= *bestDistance {
+		t.search(far, target, best, bestDistance)
+	}
+}
+
+// paletteLookup 加速的最近调色板颜色查找
+// RGB 模式且调色板 Alpha 一致时使用按颜色立方体缓存的候选列表，否则使用 k-d 树
+type paletteLookup struct {
+	colorSpace int
+	palette    [][4]uint8
+	tree       *kdTree
+
+	useCubes   bool
+	candidates [][]uint8 // 每个颜色立方体中可能成为最近颜色的调色板索引
+	ready      []bool
+
+	// k-d 树查找结果的直接映射缓存，每项为 有效位 | RGBA << 8 | 调色板索引
+	cache []uint64
+}
+
+// newPaletteLookup 为调色板构建查找结构
+// 在感知颜色空间中 working 为调色板在工作颜色空间中的坐标
+func newPaletteLookup(colorSpace int, palette [][4]uint8, working [][4]float64) *paletteLookup {
+	lookup := &paletteLookup{
+		colorSpace: colorSpace,
+		palette:    palette,
+	}
+
+	points := make([][]float64, len(palette))
+	if colorSpace == COLOR_SPACE_RGB {
+		// 所有调色板颜色的 Alpha 相同时，Alpha 差值对所有颜色都相同，不影响比较结果
+		sameAlpha := true
+		for _, color := range palette {
+			if color[3] != palette[0][3] {
+				sameAlpha = false
+				break
+			}
+		}
+		if sameAlpha {
+			cubes := 1 << (3 * LOOKUP_CUBE_BITS)
+			lookup.useCubes = true
+			lookup.candidates = make([][]uint8, cubes)
+			lookup.ready = make([]bool, cubes)
+			return lookup
+		}
+		for i, color := range palette {
+			points[i] = []float64{float64(color[0]), float64(color[1]), float64(color[2]), float64(color[3])}
+		}
+	} else {
+		for i := range palette {
+			c := working[i]
+			points[i] = []float64{c[0], c[1], c[2], c[3]}
+		}
+	}
+	lookup.tree = newKDTree(points)
+	lookup.cache = make([]uint64, 1<<LOOKUP_CACHE_BITS)
+	return lookup
+}
+
+// matches 判断查找结构是否仍对应当前的调色板
+func (pl *paletteLookup) matches(palette [][4]uint8) bool {
+	if len(pl.palette) != len(palette) {
+		return false
+	}
+	return len(palette) == 0 || &pl.palette[0] == &palette[0]
+}
+
+// find 返回最接近的调色板颜色索引，结果与线性扫描完全一致
+func (pl *paletteLookup) find(color [4]uint8) uint8 {
+	if len(pl.palette) == 0 {
+		return 0
+	}
+	if pl.useCubes {
+		return pl.findInCube(color)
+	}
+
+	// 缓存中保存完整的颜色值，命中时结果与实际查找相同
+	key := uint64(color[0])<<24 | uint64(color[1])<<16 | uint64(color[2])<<8 | uint64(color[3])
+	slot := (key * 0x9E3779B97F4A7C15) >> (64 - LOOKUP_CACHE_BITS)
+	if entry := pl.cache[slot]; entry>>40 == 1 && (entry>>8)&0xFFFFFFFF == key {
+		return uint8(entry)
+	}
+
+	var index int
+	if pl.colorSpace == COLOR_SPACE_RGB {
+		target := []float64{float64(color[0]), float64(color[1]), float64(color[2]), float64(color[3])}
+		index = pl.tree.nearest(target)
+	} else {
+		c := toWorkingSpace(pl.colorSpace, color[0], color[1], color[2])
+		index = pl.tree.nearest([]float64{c[0], c[1], c[2], float64(color[3])})
+	}
+	pl.cache[slot] = 1<<40 | key<<8 | uint64(index)
+	return uint8(index)
+}
+
+// findInCube 在颜色所在立方体的候选列表中查找最近颜色
+func (pl *paletteLookup) findInCube(color [4]uint8) uint8 {
+	shift := 8 - LOOKUP_CUBE_BITS
+	r := int(color[0] >> shift)
+	g := int(color[1] >> shift)
+	b := int(color[2] >> shift)
+	cube := (r<<LOOKUP_CUBE_BITS|g)<<LOOKUP_CUBE_BITS | b
+
+	if !pl.ready[cube] {
+		pl.candidates[cube] = pl.cubeCandidates(r, g, b)
+		pl.ready[cube] = true
+	}
+
+	// 候选列表按索引升序排列，严格小于保证距离相同时取索引最小的颜色
+	minDistance := uint32(0)
+	closestIndex := uint8(0)
+	for i, idx := range pl.candidates[cube] {
+		distance := colorDistanceSquared(color, pl.palette[idx])
+		if i == 0 || distance < minDistance {
+			minDistance = distance
+			closestIndex = idx
+		}
+	}
+	return closestIndex
+}
+
+// cubeCandidates 计算立方体内任意颜色的最近调色板颜色的候选集合
+// 调色板颜色到立方体的最小距离不超过所有颜色到立方体最大距离的最小值时，才可能成为最近颜色
+func (pl *paletteLookup) cubeCandidates(r, g, b int) []uint8 {
+	shift := 8 - LOOKUP_CUBE_BITS
+	size := 1 << shift
+	lo := [3]int{r << shift, g << shift, b << shift}
+
+	minDistances := make([]int, len(pl.palette))
+	bound := -1
+	for i, color := range pl.palette {
+		minDistance, maxDistance := 0, 0
+		for c := 0; c < 3; c++ {
+			v := int(color[c])
+			low, high := lo[c], lo[c]+size-1
+			if v < low {
+				minDistance += (low - v) * (low - v)
+			} else if v > high {
+				minDistance += (v - high) * (v - high)
+			}
+			far := v - low
+			if high-v > far {
+				far = high - v
+			}
+			maxDistance += far * far
+		}
+		minDistances[i] = minDistance
+		if bound < 0 || maxDistance < bound {
+			bound = maxDistance
+		}
+	}
+
+	candidates := make([]uint8, 0)
+	for i, minDistance := range minDistances {
+		if minDistance <= bound {
+			candidates = append(candidates, uint8(i))
+		}
+	}
+	return candidates
+}
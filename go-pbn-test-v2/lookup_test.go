@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomLookupPalette 生成位于粗网格上的随机调色板，包含重复颜色，使等距的情况经常出现
+func randomLookupPalette(rng *rand.Rand, size int, mixedAlpha bool) [][4]uint8 {
+	palette := make([][4]uint8, size)
+	for i := range palette {
+		if i > 0 && rng.Intn(8) == 0 {
+			palette[i] = palette[rng.Intn(i)]
+			continue
+		}
+		alpha := uint8(255)
+		if mixedAlpha {
+			alpha = uint8(rng.Intn(5) * 60)
+		}
+		palette[i] = [4]uint8{uint8(rng.Intn(32) * 8), uint8(rng.Intn(32) * 8), uint8(rng.Intn(32) * 8), alpha}
+	}
+	return palette
+}
+
+func TestPaletteLookupMatchesLinear(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for _, colorSpace := range []int{COLOR_SPACE_RGB, COLOR_SPACE_LAB, COLOR_SPACE_OKLAB} {
+		for _, mixedAlpha := range []bool{false, true} {
+			for _, size := range []int{1, 2, 7, 64, 256} {
+				options := DefaultQuantizerOptions()
+				options.ColorSpace = colorSpace
+				quantizer := NewQuantizerWithPalette(NewBitmap(1, 1), randomLookupPalette(rng, size, mixedAlpha), options)
+				quantizer.BuildPalette()
+				quantizer.preparePaletteLookup()
+
+				for i := 0; i < 4000; i++ {
+					// 一半的查询位于网格中点上，与多个调色板颜色等距
+					var color [4]uint8
+					for c := range color {
+						if i%2 == 0 {
+							color[c] = uint8(rng.Intn(64) * 4)
+						} else {
+							color[c] = uint8(rng.Intn(256))
+						}
+					}
+					if !mixedAlpha {
+						color[3] = 255
+					}
+					// 查询两次，第二次命中缓存
+					for pass := 0; pass < 2; pass++ {
+						got := quantizer.lookup.find(color)
+						want := quantizer.findClosestPaletteIndexLinear(color)
+						if got != want {
+							t.Fatalf("color space %d, %d colors, mixed alpha %v: find(%v) = %d %v, linear = %d %v",
+								colorSpace, size, mixedAlpha, color, got, quantizer.Palette[got], want, quantizer.Palette[want])
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -2,6 +2,8 @@
 package main
 
 import (
+	"encoding/binary"
+	"fmt"
 	"math"
 	"syscall/js"
 )
@@ -44,10 +46,681 @@ func processImage(this js.Value, args []js.Value) interface{} {
 	return processedData
 }
 
+// quantizeImage 对图像进行颜色量化
+// 参数：data(Uint8ClampedArray, RGBA), width, height, options({colors, palette, algorithm: "wu"|"median-cut"|"octree"|"neuquant",
+// colorSpace: "rgb"|"lab"|"oklab", histogramBits, maxHistogramBytes, dither, ditherStrength, serpentine, alphaThreshold, quantizeAlpha,
+// lockedColors, lockedRadius, refineIterations, refineThreshold, autoColors, maxColors, targetError, minGain, mergeDeltaE,
+// sortPalette: "none"|"luminance"|"hue"|"frequency"|"nearest", metrics, facets, labels, minFacetArea, maxFacets, fontSize, minFontSize})
+// palette/lockedColors 为颜色数组，每个颜色是 "#rrggbb" 字符串、[r, g, b, a?] 数组或 {r, g, b, a?} / {hex} 对象
+// autoColors/maxColors/targetError/minGain 基于 Wu 算法的误差曲线，只能与 algorithm: "wu" 一起使用，
+// 与其他算法同时设置时输出错误并按 colors 生成调色板
+// histogramBits 超过 maxHistogramBytes（默认 32MB）时自动降低，结果中的 histogramBits 为实际使用的位数
+// 返回：{data: Uint8ClampedArray, palette: Uint8Array(RGBA), indices: Uint8Array, colors: number,
+// histogramBits?, autoColors?, metrics?, facets?, facetCount?, facetMap?}
+func quantizeImage(this js.Value, args []js.Value) interface{} {
+	bitmap, options, ok := parseImageArgs("quantizeImage", args)
+	if !ok {
+		return js.Null()
+	}
+	return quantizeBitmap(bitmap, options)
+}
+
+// quantizeBitmap 按选项处理 Bitmap 并转换为 quantizeImage 返回的 JavaScript 对象
+func quantizeBitmap(bitmap *Bitmap, options js.Value) js.Value {
+	withFacets := getBoolOption(options, "facets", false)
+	pipeline := runPipeline(bitmap, options, withFacets)
+	colorMap, facetResult, autoResult := pipeline.colorMap, pipeline.facetResult, pipeline.autoResult
+
+	result := colorMapToJS(colorMap)
+	if pipeline.histogramBits > 0 {
+		result.Set("histogramBits", pipeline.histogramBits)
+	}
+	if autoResult != nil {
+		curve := make([]interface{}, len(autoResult.ErrorCurve))
+		for i, value := range autoResult.ErrorCurve {
+			curve[i] = value
+		}
+		result.Set("autoColors", js.ValueOf(map[string]interface{}{
+			"colors":     autoResult.Colors,
+			"adaptive":   autoResult.Adaptive,
+			"errorCurve": curve,
+		}))
+	}
+	if getBoolOption(options, "metrics", false) {
+		result.Set("metrics", metricsToJS(ComputeQualityMetrics(bitmap, colorMap)))
+	}
+	if withFacets {
+		var labels []*FacetLabel
+		if getBoolOption(options, "labels", false) {
+			labels = PlaceLabels(facetResult, LabelOptions{
+				MinFontSize: getFloatOption(options, "minFontSize", 0),
+				MaxFontSize: getFloatOption(options, "fontSize", 0),
+			})
+		}
+		result.Set("facets", facetsToJS(facetResult, labels))
+		result.Set("facetCount", facetResult.Count())
+
+		facetMap := js.Global().Get("Uint32Array").New(len(facetResult.FacetMap.Data))
+		copyUint32ToJS(facetMap, facetResult.FacetMap.Data)
+		result.Set("facetMap", facetMap)
+	}
+	return result
+}
+
+// exportSVG 量化图像并导出填色模板 SVG
+// 参数：data, width, height, options(quantizeImage 的选项，以及 {strokeWidth, strokeColor, fill: "none"|"white"|"palette", labels, fontSize, minFontSize, labelColor})
+// 返回：SVG 字符串
+func exportSVG(this js.Value, args []js.Value) interface{} {
+	bitmap, options, ok := parseImageArgs("exportSVG", args)
+	if !ok {
+		return js.Null()
+	}
+
+	pipeline := runPipeline(bitmap, options, true)
+	colorMap, facetResult := pipeline.colorMap, pipeline.facetResult
+	borders := TraceBorders(facetResult)
+
+	svgOptions := DefaultSVGOptions()
+	svgOptions.StrokeWidth = getFloatOption(options, "strokeWidth", svgOptions.StrokeWidth)
+	svgOptions.StrokeColor = getStringOption(options, "strokeColor", svgOptions.StrokeColor)
+	svgOptions.ShowLabels = getBoolOption(options, "labels", svgOptions.ShowLabels)
+	svgOptions.FontSize = getFloatOption(options, "fontSize", svgOptions.FontSize)
+	svgOptions.MinFontSize = getFloatOption(options, "minFontSize", svgOptions.MinFontSize)
+	svgOptions.LabelColor = getStringOption(options, "labelColor", svgOptions.LabelColor)
+	switch getStringOption(options, "fill", "white") {
+	case "none":
+		svgOptions.FillMode = SVG_FILL_NONE
+	case "palette":
+		svgOptions.FillMode = SVG_FILL_PALETTE
+	default:
+		svgOptions.FillMode = SVG_FILL_WHITE
+	}
+
+	return ExportSVG(colorMap, facetResult, borders, svgOptions)
+}
+
+// parseImageArgs 解析 data, width, height, [options] 参数并构建 Bitmap
+func parseImageArgs(name string, args []js.Value) (*Bitmap, js.Value, bool) {
+	if len(args) < 3 {
+		js.Global().Get("console").Call("error", name+" requires at least 3 arguments: data, width, height, [options]")
+		return nil, js.Undefined(), false
+	}
+
+	data := args[0]
+	width := args[1].Int()
+	height := args[2].Int()
+
+	var options js.Value
+	if len(args) > 3 {
+		options = args[3]
+	}
+
+	// 检查数据长度是否匹配
+	byteLength := data.Get("byteLength").Int()
+	if !validImageSize(width, height, byteLength) {
+		js.Global().Get("console").Call("error", name+": data length does not match width*height*4")
+		return nil, options, false
+	}
+
+	byteSlice := make([]uint8, byteLength)
+	js.CopyBytesToGo(byteSlice, data)
+
+	return NewBitmapWithData(uint32(width), uint32(height), byteSlice), options, true
+}
+
+// validImageSize 检查宽高是否与 RGBA 数据长度一致
+// 在 int 中计算 width*height*4，避免 uint32 溢出后与 byteLength 意外相等
+func validImageSize(width, height, byteLength int) bool {
+	if width < 0 || height < 0 || width > math.MaxUint32 || height > math.MaxUint32 {
+		return false
+	}
+	return width*height*4 == byteLength
+}
+
+// pipelineResult runPipeline 的结果
+type pipelineResult struct {
+	colorMap      *ColorMap
+	facetResult   *FacetResult     // 需要区域信息或设置了清理选项时才会提取区域，否则为 nil
+	autoResult    *AutoColorResult // 仅在 Wu 算法的自动颜色数模式下不为 nil
+	histogramBits int              // Wu 直方图实际使用的有效位数，未使用直方图时为 0
+}
+
+// runPipeline 按选项执行量化和区域清理
+func runPipeline(bitmap *Bitmap, options js.Value, withFacets bool) pipelineResult {
+	colors := getIntOption(options, "colors", DEFAULT_COLORS)
+	reduceOptions := FacetReduceOptions{
+		MinArea:   getIntOption(options, "minFacetArea", 0),
+		MaxFacets: getIntOption(options, "maxFacets", 0),
+	}
+
+	// 构建调色板并映射像素
+	quantizerOptions := getQuantizerOptions(options)
+	var quantizer ColorQuantizer
+	var autoResult *AutoColorResult
+	algorithm := ParseQuantizerAlgorithm(getStringOption(options, "algorithm", "wu"))
+	autoColors := getBoolOption(options, "autoColors", false)
+	if autoColors && algorithm != QUANTIZER_WU {
+		js.Global().Get("console").Call("error", "autoColors is only supported with algorithm \"wu\", using colors instead")
+		autoColors = false
+	}
+	if palette := getPaletteOption(options, "palette"); len(palette) > 0 {
+		quantizer = NewQuantizerWithPalette(bitmap, palette, quantizerOptions)
+		quantizer.BuildPalette()
+	} else if autoColors {
+		// 自动模式下 colors 不生效，以 maxColors 作为上限
+		autoOptions := DefaultAutoColorOptions()
+		autoOptions.TargetError = getFloatOption(options, "targetError", autoOptions.TargetError)
+		autoOptions.MinGain = getFloatOption(options, "minGain", autoOptions.MinGain)
+		wu := NewQuantizerWithOptions(bitmap, getIntOption(options, "maxColors", DEFAULT_AUTO_MAX_COLORS), quantizerOptions)
+		_, autoResult = wu.BuildPaletteAuto(autoOptions)
+		quantizer = wu
+	} else {
+		quantizer = NewColorQuantizer(algorithm, bitmap, colors, quantizerOptions)
+		quantizer.BuildPalette()
+	}
+	colorMap := quantizer.MapPixels()
+
+	// 其他算法没有记录权重，合并时使用 ColorMap 中的像素数
+	mergeOptions := MergeOptions{
+		ColorSpace:  quantizerOptions.ColorSpace,
+		LockedCount: len(limitLockedColors(quantizerOptions.LockedColors, quantizerOptions.Alpha)),
+	}
+	if wu, ok := quantizer.(*Quantizer); ok {
+		mergeOptions = wu.MergeOptions()
+	}
+	MergeSimilarColors(colorMap, getFloatOption(options, "mergeDeltaE", 0), mergeOptions)
+
+	// 直方图超过内存上限时会降低精度，记录实际使用的位数
+	histogramBits := 0
+	if wu, ok := quantizer.(*Quantizer); ok && !wu.fixedPalette {
+		histogramBits = wu.SignificantBits
+		if requested := min(quantizerOptions.HistogramBits, MAX_SIGNIFICANT_BITS); histogramBits < requested {
+			js.Global().Get("console").Call("warn",
+				fmt.Sprintf("histogramBits reduced from %d to %d to stay within maxHistogramBytes", requested, histogramBits))
+		}
+	}
+
+	// 清理小区域会修改 colorMap，因此需要在输出之前完成
+	var facetResult *FacetResult
+	if withFacets || reduceOptions.MinArea > 1 || reduceOptions.MaxFacets > 0 {
+		facetResult = BuildFacets(colorMap)
+		facetResult = ReduceFacets(colorMap, facetResult, reduceOptions)
+	}
+
+	// 在清理区域之后排序，按像素数排序时使用最终的像素数
+	if sortMode := ParsePaletteSort(getStringOption(options, "sortPalette", "none")); sortMode != PALETTE_SORT_NONE {
+		remap := SortPalette(colorMap, sortMode)
+		if facetResult != nil {
+			facetResult.RemapColors(remap)
+		}
+	}
+	return pipelineResult{
+		colorMap:      colorMap,
+		facetResult:   facetResult,
+		autoResult:    autoResult,
+		histogramBits: histogramBits,
+	}
+}
+
+// getQuantizerOptions 从 options 对象中读取量化器选项
+func getQuantizerOptions(options js.Value) QuantizerOptions {
+	quantizerOptions := DefaultQuantizerOptions()
+	quantizerOptions.ColorSpace = ParseColorSpace(getStringOption(options, "colorSpace", "rgb"))
+	quantizerOptions.Dither.Mode = ParseDitherMode(getStringOption(options, "dither", "none"))
+	// 强度超出 0~1 时会放大误差，按范围截取
+	quantizerOptions.Dither.Strength = math.Min(math.Max(getFloatOption(options, "ditherStrength", quantizerOptions.Dither.Strength), 0), 1)
+	quantizerOptions.Dither.Serpentine = getBoolOption(options, "serpentine", quantizerOptions.Dither.Serpentine)
+	quantizerOptions.Alpha.Threshold = clampToUint8(getFloatOption(options, "alphaThreshold", 0))
+	quantizerOptions.Alpha.QuantizeAlpha = getBoolOption(options, "quantizeAlpha", false)
+	quantizerOptions.LockedColors = getPaletteOption(options, "lockedColors")
+	quantizerOptions.LockedRadius = getFloatOption(options, "lockedRadius", quantizerOptions.LockedRadius)
+	quantizerOptions.HistogramBits = getIntOption(options, "histogramBits", quantizerOptions.HistogramBits)
+	quantizerOptions.MaxHistogramBytes = getIntOption(options, "maxHistogramBytes", quantizerOptions.MaxHistogramBytes)
+	quantizerOptions.Refine.Iterations = getIntOption(options, "refineIterations", quantizerOptions.Refine.Iterations)
+	quantizerOptions.Refine.Threshold = getFloatOption(options, "refineThreshold", quantizerOptions.Refine.Threshold)
+	return quantizerOptions
+}
+
+// colorMapToJS 将 ColorMap 转换为 JavaScript 对象
+func colorMapToJS(colorMap *ColorMap) js.Value {
+	pixels := colorMap.ToImage().Data
+	pixelData := js.Global().Get("Uint8ClampedArray").New(len(pixels))
+	js.CopyBytesToJS(pixelData, pixels)
+
+	// 调色板按 RGBA 顺序展开为一维数组
+	paletteBytes := make([]uint8, 0, len(colorMap.Colors)*4)
+	for _, color := range colorMap.Colors {
+		paletteBytes = append(paletteBytes, color[0], color[1], color[2], color[3])
+	}
+	palette := js.Global().Get("Uint8Array").New(len(paletteBytes))
+	js.CopyBytesToJS(palette, paletteBytes)
+
+	indices := js.Global().Get("Uint8Array").New(len(colorMap.MappedIndices.Data))
+	js.CopyBytesToJS(indices, colorMap.MappedIndices.Data)
+
+	return js.ValueOf(map[string]interface{}{
+		"data":    pixelData,
+		"palette": palette,
+		"indices": indices,
+		"colors":  len(colorMap.Colors),
+		"width":   colorMap.Width,
+		"height":  colorMap.Height,
+	})
+}
+
+// metricsToJS 将质量指标转换为 JavaScript 对象
+func metricsToJS(metrics *QualityMetrics) js.Value {
+	entries := make([]interface{}, len(metrics.Entries))
+	for i, entry := range metrics.Entries {
+		entries[i] = map[string]interface{}{
+			"pixelCount":   entry.PixelCount,
+			"squaredError": entry.SquaredError,
+			"errorShare":   entry.ErrorShare,
+			"meanDeltaE":   entry.MeanDeltaE,
+		}
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pixelCount": metrics.PixelCount,
+		"mse":        metrics.MSE,
+		"psnr":       metrics.PSNR,
+		"ssim":       metrics.SSIM,
+		"meanDeltaE": metrics.MeanDeltaE,
+		"maxDeltaE":  metrics.MaxDeltaE,
+		"entries":    entries,
+	})
+}
+
+// facetsToJS 将区域列表转换为 JavaScript 数组，已删除的区域会被跳过
+// labels 不为 nil 时附带每个区域的编号位置
+func facetsToJS(facetResult *FacetResult, labels []*FacetLabel) js.Value {
+	list := js.Global().Get("Array").New()
+	for _, facet := range facetResult.Facets {
+		if facet == nil {
+			continue
+		}
+		neighbours := make([]interface{}, len(facet.NeighbourFacets))
+		for i, neighbour := range facet.NeighbourFacets {
+			neighbours[i] = neighbour
+		}
+		item := map[string]interface{}{
+			"id":         facet.ID,
+			"color":      int(facet.ColorIndex),
+			"pointCount": facet.PointCount,
+			"bbox": map[string]interface{}{
+				"minX": facet.BBox.MinX,
+				"minY": facet.BBox.MinY,
+				"maxX": facet.BBox.MaxX,
+				"maxY": facet.BBox.MaxY,
+			},
+			"neighbours": neighbours,
+		}
+		if labels != nil && labels[facet.ID] != nil {
+			label := labels[facet.ID]
+			item["label"] = map[string]interface{}{
+				"x":        label.X,
+				"y":        label.Y,
+				"radius":   label.Radius,
+				"fontSize": label.FontSize,
+				"fits":     label.Fits,
+			}
+		}
+		list.Call("push", js.ValueOf(item))
+	}
+	return list
+}
+
+// copyUint32ToJS 将 []uint32 复制到 JavaScript 的 Uint32Array
+func copyUint32ToJS(dst js.Value, src []uint32) {
+	bytes := make([]uint8, len(src)*4)
+	for i, value := range src {
+		binary.LittleEndian.PutUint32(bytes[i*4:], value)
+	}
+	view := js.Global().Get("Uint8Array").New(dst.Get("buffer"), dst.Get("byteOffset"), dst.Get("byteLength"))
+	js.CopyBytesToJS(view, bytes)
+}
+
+// getIntOption 从 options 对象中读取整数选项，不存在时返回默认值
+func getIntOption(options js.Value, name string, defaultValue int) int {
+	if options.Type() != js.TypeObject {
+		return defaultValue
+	}
+	value := options.Get(name)
+	if value.Type() != js.TypeNumber {
+		return defaultValue
+	}
+	return value.Int()
+}
+
+// getFloatOption 从 options 对象中读取浮点数选项，不存在时返回默认值
+func getFloatOption(options js.Value, name string, defaultValue float64) float64 {
+	if options.Type() != js.TypeObject {
+		return defaultValue
+	}
+	value := options.Get(name)
+	if value.Type() != js.TypeNumber {
+		return defaultValue
+	}
+	return value.Float()
+}
+
+// getStringOption 从 options 对象中读取字符串选项，不存在时返回默认值
+func getStringOption(options js.Value, name string, defaultValue string) string {
+	if options.Type() != js.TypeObject {
+		return defaultValue
+	}
+	value := options.Get(name)
+	if value.Type() != js.TypeString {
+		return defaultValue
+	}
+	return value.String()
+}
+
+// getPaletteOption 从 options 对象中读取调色板
+// 每个颜色可以是 "#rrggbb" 字符串、[r, g, b, a?] 数组或 {r, g, b, a?} / {hex} 对象
+func getPaletteOption(options js.Value, name string) [][4]uint8 {
+	if options.Type() != js.TypeObject {
+		return nil
+	}
+	value := options.Get(name)
+	if value.Type() != js.TypeObject || !js.Global().Get("Array").Call("isArray", value).Bool() {
+		return nil
+	}
+
+	palette := make([][4]uint8, 0, value.Length())
+	for i := 0; i < value.Length(); i++ {
+		item := value.Index(i)
+		color, ok := [4]uint8{}, false
+		switch {
+		case item.Type() == js.TypeString:
+			color, ok = ParseHexColor(item.String())
+		case item.Type() == js.TypeObject && js.Global().Get("Array").Call("isArray", item).Bool():
+			color, ok = jsArrayColor(item)
+		case item.Type() == js.TypeObject:
+			if hex := item.Get("hex"); hex.Type() == js.TypeString {
+				color, ok = ParseHexColor(hex.String())
+				break
+			}
+			color, ok = jsObjectColor(item)
+		}
+		if !ok {
+			js.Global().Get("console").Call("error", fmt.Sprintf("%s: ignoring invalid color at index %d", name, i))
+			continue
+		}
+		palette = append(palette, color)
+	}
+	return palette
+}
+
+// jsArrayColor 读取 [r, g, b, a?] 形式的颜色，分量必须是数字，超出 0~255 的值会被截断
+func jsArrayColor(item js.Value) ([4]uint8, bool) {
+	color := [4]uint8{0, 0, 0, 255}
+	if item.Length() < 3 {
+		return color, false
+	}
+	for c := 0; c < 4 && c < item.Length(); c++ {
+		if !jsColorComponent(item.Index(c), &color[c]) {
+			return color, false
+		}
+	}
+	return color, true
+}
+
+// jsObjectColor 读取 {r, g, b, a?} 形式的颜色，分量必须是数字，超出 0~255 的值会被截断
+func jsObjectColor(item js.Value) ([4]uint8, bool) {
+	color := [4]uint8{0, 0, 0, 255}
+	for c, key := range []string{"r", "g", "b", "a"} {
+		component := item.Get(key)
+		if key == "a" && component.Type() == js.TypeUndefined {
+			break
+		}
+		if !jsColorComponent(component, &color[c]) {
+			return color, false
+		}
+	}
+	return color, true
+}
+
+// jsColorComponent 读取一个颜色分量，不是数字或为 NaN 时返回 false
+func jsColorComponent(value js.Value, component *uint8) bool {
+	if value.Type() != js.TypeNumber || math.IsNaN(value.Float()) {
+		return false
+	}
+	*component = clampToUint8(value.Float())
+	return true
+}
+
+// getBoolOption 从 options 对象中读取布尔选项，不存在时返回默认值
+func getBoolOption(options js.Value, name string, defaultValue bool) bool {
+	if options.Type() != js.TypeObject {
+		return defaultValue
+	}
+	value := options.Get(name)
+	if value.Type() != js.TypeBoolean {
+		return defaultValue
+	}
+	return value.Bool()
+}
+
+// exportPalette 量化图像并导出调色板
+// 参数：data, width, height, options(quantizeImage 的选项，以及 {format: "gpl"|"ase"|"aco"|"json"|"css", name})
+// 返回：文本格式返回字符串，ase/aco 返回 Uint8Array
+func exportPalette(this js.Value, args []js.Value) interface{} {
+	bitmap, options, ok := parseImageArgs("exportPalette", args)
+	if !ok {
+		return js.Null()
+	}
+
+	colorMap := runPipeline(bitmap, options, false).colorMap
+	format := ParsePaletteFormat(getStringOption(options, "format", "gpl"))
+	data := ExportPalette(colorMap, format, getStringOption(options, "name", DEFAULT_PALETTE_NAME))
+	if !IsBinaryPaletteFormat(format) {
+		return string(data)
+	}
+
+	result := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(result, data)
+	return result
+}
+
+// exportPNG 量化图像并编码为索引颜色 PNG
+// 参数：data, width, height, options(quantizeImage 的选项)
+// 返回：Uint8Array(PNG 文件内容)
+func exportPNG(this js.Value, args []js.Value) interface{} {
+	bitmap, options, ok := parseImageArgs("exportPNG", args)
+	if !ok {
+		return js.Null()
+	}
+
+	colorMap := runPipeline(bitmap, options, false).colorMap
+	data, err := IndexedPNGBytes(colorMap)
+	if err != nil {
+		js.Global().Get("console").Call("error", "exportPNG: "+err.Error())
+		return js.Null()
+	}
+
+	result := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(result, data)
+	return result
+}
+
+// exportGIF 量化图像并编码为静态 GIF
+// 参数：data, width, height, options(quantizeImage 的选项)
+// 返回：Uint8Array(GIF 文件内容)
+func exportGIF(this js.Value, args []js.Value) interface{} {
+	bitmap, options, ok := parseImageArgs("exportGIF", args)
+	if !ok {
+		return js.Null()
+	}
+
+	colorMap := runPipeline(bitmap, options, false).colorMap
+	data, err := GIFBytes(colorMap)
+	if err != nil {
+		js.Global().Get("console").Call("error", "exportGIF: "+err.Error())
+		return js.Null()
+	}
+
+	result := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(result, data)
+	return result
+}
+
+// exportAnimatedGIF 量化一组帧并编码为动画 GIF
+// 参数：frames(由 {data, width, height} 组成的数组，可以直接传入 ImageData),
+// options({colors, paletteMode: "global"|"local", delay, delays, disposal: "none"|"background"|"previous", loop}，
+// 以及 quantizeImage 中的 colorSpace, dither, ditherStrength, serpentine, alphaThreshold, quantizeAlpha, lockedColors,
+// lockedRadius, histogramBits, maxHistogramBytes, refineIterations, refineThreshold)，delay/delays 的单位为毫秒，loop 为 -1 时只播放一次
+// 帧始终使用 Wu 算法量化，algorithm, palette, autoColors, mergeDeltaE, sortPalette 不生效
+// 返回：Uint8Array(GIF 文件内容)
+func exportAnimatedGIF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || !js.Global().Get("Array").Call("isArray", args[0]).Bool() {
+		js.Global().Get("console").Call("error", "exportAnimatedGIF requires at least 1 argument: frames, [options]")
+		return js.Null()
+	}
+	var options js.Value
+	if len(args) > 1 {
+		options = args[1]
+	}
+
+	frames := make([]*Bitmap, args[0].Length())
+	for i := range frames {
+		frame := args[0].Index(i)
+		if frame.Type() != js.TypeObject || frame.Get("width").Type() != js.TypeNumber ||
+			frame.Get("height").Type() != js.TypeNumber || frame.Get("data").Type() != js.TypeObject {
+			js.Global().Get("console").Call("error", fmt.Sprintf("exportAnimatedGIF: frame %d is not {data, width, height}", i))
+			return js.Null()
+		}
+		width := frame.Get("width").Int()
+		height := frame.Get("height").Int()
+		data := frame.Get("data")
+		if !validImageSize(width, height, data.Get("byteLength").Int()) {
+			js.Global().Get("console").Call("error", "exportAnimatedGIF: frame data length does not match width*height*4")
+			return js.Null()
+		}
+		bitmap := NewBitmap(uint32(width), uint32(height))
+		js.CopyBytesToGo(bitmap.Data, data)
+		frames[i] = bitmap
+	}
+
+	for _, name := range []string{"algorithm", "palette", "autoColors", "mergeDeltaE", "sortPalette"} {
+		if options.Type() == js.TypeObject && !options.Get(name).IsUndefined() {
+			js.Global().Get("console").Call("warn", "exportAnimatedGIF: option "+name+" is not supported and will be ignored")
+		}
+	}
+
+	colorMaps := QuantizeFrames(
+		frames,
+		getIntOption(options, "colors", DEFAULT_COLORS),
+		getQuantizerOptions(options),
+		ParseGIFPaletteMode(getStringOption(options, "paletteMode", "global")),
+	)
+
+	data, err := AnimatedGIFBytes(colorMaps, getGIFOptions(options))
+	if err != nil {
+		js.Global().Get("console").Call("error", "exportAnimatedGIF: "+err.Error())
+		return js.Null()
+	}
+
+	result := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(result, data)
+	return result
+}
+
+// getGIFOptions 从 JavaScript 选项对象中读取动画 GIF 的编码选项
+func getGIFOptions(options js.Value) GIFOptions {
+	// GIF 的帧间隔以 1/100 秒为单位，负数的间隔使用 0
+	gifOptions := DefaultGIFOptions()
+	gifOptions.Delay = max(getIntOption(options, "delay", gifOptions.Delay*10), 0) / 10
+	if options.Type() == js.TypeObject && options.Get("delays").Type() == js.TypeObject {
+		delays := options.Get("delays")
+		gifOptions.Delays = make([]int, delays.Length())
+		for i := range gifOptions.Delays {
+			// 非数字或负数的间隔使用默认值
+			gifOptions.Delays[i] = gifOptions.Delay
+			if delay := delays.Index(i); delay.Type() == js.TypeNumber && delay.Int() >= 0 {
+				gifOptions.Delays[i] = delay.Int() / 10
+			}
+		}
+	}
+	if disposal := getStringOption(options, "disposal", ""); disposal != "" {
+		gifOptions.Disposal = ParseGIFDisposal(disposal)
+	}
+	gifOptions.LoopCount = getIntOption(options, "loop", gifOptions.LoopCount)
+	return gifOptions
+}
+
+// quantizeFile 解码图片文件并执行与 quantizeImage 相同的处理
+// 参数：file(File/Blob，支持 PNG/JPEG/GIF/BMP/TIFF/WebP), options(quantizeImage 的选项)
+// 返回：Promise，结果与 quantizeImage 相同，并增加 format(识别出的格式)
+func quantizeFile(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		js.Global().Get("console").Call("error", "quantizeFile requires at least 1 argument: file, [options]")
+		return js.Null()
+	}
+	file := args[0]
+	var options js.Value
+	if len(args) > 1 {
+		options = args[1]
+	}
+
+	// Promise 的 executor 在构造时同步执行，构造完成后即可释放
+	executor := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resolve := args[0]
+		reject := args[1]
+
+		// 读取成功或失败时只会调用其中一个回调，两者都需要在此时释放
+		var onLoad, onError js.Func
+		release := func() {
+			onLoad.Release()
+			onError.Release()
+		}
+		onLoad = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			defer release()
+
+			uint8Array := js.Global().Get("Uint8Array").New(args[0])
+			byteSlice := make([]byte, uint8Array.Length())
+			js.CopyBytesToGo(byteSlice, uint8Array)
+
+			bitmap, format, err := DecodeImage(byteSlice)
+			if err != nil {
+				reject.Invoke(js.ValueOf(err.Error()))
+				return nil
+			}
+
+			result := quantizeBitmap(bitmap, options)
+			result.Set("format", format)
+			resolve.Invoke(result)
+			return nil
+		})
+		onError = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			defer release()
+
+			reason := js.Undefined()
+			if len(args) > 0 {
+				reason = args[0]
+			}
+			reject.Invoke(reason)
+			return nil
+		})
+		file.Call("arrayBuffer").Call("then", onLoad, onError)
+		return nil
+	})
+	defer executor.Release()
+
+	return js.Global().Get("Promise").New(executor)
+}
+
 func main() {
 	c := make(chan struct{}, 0)
 
 	js.Global().Set("processImage", js.FuncOf(processImage))
+	js.Global().Set("quantizeImage", js.FuncOf(quantizeImage))
+	js.Global().Set("quantizeFile", js.FuncOf(quantizeFile))
+	js.Global().Set("exportSVG", js.FuncOf(exportSVG))
+	js.Global().Set("exportPalette", js.FuncOf(exportPalette))
+	js.Global().Set("exportPNG", js.FuncOf(exportPNG))
+	js.Global().Set("exportGIF", js.FuncOf(exportGIF))
+	js.Global().Set("exportAnimatedGIF", js.FuncOf(exportAnimatedGIF))
 
 	<-c
 }
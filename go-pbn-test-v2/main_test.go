@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"math"
+	"syscall/js"
+	"testing"
+)
+
+func TestGetPaletteOption(t *testing.T) {
+	options := js.ValueOf(map[string]interface{}{
+		"palette": []interface{}{
+			"#ff0000",
+			[]interface{}{0, 255, 0},
+			[]interface{}{1, 2, 3, 4},
+			map[string]interface{}{"r": 5, "g": 6, "b": 7},
+			map[string]interface{}{"r": 5, "g": 6, "b": 7, "a": 300},
+			map[string]interface{}{"hex": "#0000ff80"},
+			// 以下均为无效颜色
+			"#zzz",
+			[]interface{}{1, 2},
+			[]interface{}{1, "2", 3},
+			[]interface{}{1, math.NaN(), 3},
+			map[string]interface{}{"r": "x", "g": 1, "b": 2},
+			map[string]interface{}{"r": 1, "g": 2},
+			map[string]interface{}{"r": 1, "g": 2, "b": 3, "a": "opaque"},
+			42,
+			nil,
+		},
+	})
+	want := [][4]uint8{{255, 0, 0, 255}, {0, 255, 0, 255}, {1, 2, 3, 4}, {5, 6, 7, 255}, {5, 6, 7, 255}, {0, 0, 255, 128}}
+	if got := getPaletteOption(options, "palette"); !samePalette(got, want) {
+		t.Fatalf("palette %v, want %v", got, want)
+	}
+
+	for _, options := range []js.Value{js.Undefined(), js.ValueOf(map[string]interface{}{"palette": "#ff0000"})} {
+		if got := getPaletteOption(options, "palette"); got != nil {
+			t.Fatalf("palette %v from %v, want nil", got, options)
+		}
+	}
+}
+
+func TestFixedPaletteUsedVerbatim(t *testing.T) {
+	catalog := [][4]uint8{{200, 30, 30, 255}, {30, 200, 30, 255}, {30, 30, 200, 255}, {240, 240, 240, 255}}
+	entries := make([]interface{}, len(catalog))
+	for i, color := range catalog {
+		entries[i] = []interface{}{int(color[0]), int(color[1]), int(color[2])}
+	}
+	// 透明像素存在且设置了 alphaThreshold 时，调色板末尾追加透明颜色
+	withTransparent := append(append([][4]uint8{}, catalog...), [4]uint8{0, 0, 0, 0})
+
+	for _, algorithm := range []string{"wu", "median-cut", "octree", "neuquant"} {
+		for _, threshold := range []int{0, 128} {
+			options := js.ValueOf(map[string]interface{}{
+				"palette":        entries,
+				"algorithm":      algorithm,
+				"colors":         2,
+				"alphaThreshold": threshold,
+				"colorSpace":     "lab",
+				"dither":         "floyd-steinberg",
+			})
+			colorMap := runPipeline(gradientBitmap(32, 16), options, false).colorMap
+
+			want := catalog
+			if threshold > 0 {
+				want = withTransparent
+			}
+			if !samePalette(colorMap.Colors, want) {
+				t.Fatalf("%s, threshold %d: palette %v, want %v", algorithm, threshold, colorMap.Colors, want)
+			}
+			for i, index := range colorMap.MappedIndices.Data {
+				if int(index) >= len(want) {
+					t.Fatalf("%s: pixel %d mapped to %d", algorithm, i, index)
+				}
+				// 第 0 列是透明的
+				if transparent := i%32 == 0 && threshold > 0; transparent != (int(index) == len(catalog)) {
+					t.Fatalf("%s: pixel %d mapped to %d", algorithm, i, index)
+				}
+			}
+		}
+	}
+}
+
+func TestGetGIFOptions(t *testing.T) {
+	gifOptions := getGIFOptions(js.ValueOf(map[string]interface{}{
+		"delay":    -50,
+		"delays":   []interface{}{-20, "slow", 120},
+		"disposal": "background",
+		"loop":     -1,
+	}))
+	// 负数的间隔按 0 处理，delays 中的无效值使用 delay
+	if gifOptions.Delay != 0 || !equalInts(gifOptions.Delays, []int{0, 0, 12}) {
+		t.Fatalf("delay %d, delays %v", gifOptions.Delay, gifOptions.Delays)
+	}
+	if gifOptions.Disposal != gif.DisposalBackground || gifOptions.LoopCount != -1 {
+		t.Fatalf("disposal %d, loop %d", gifOptions.Disposal, gifOptions.LoopCount)
+	}
+
+	if gifOptions := getGIFOptions(js.Undefined()); gifOptions.Delay != DEFAULT_GIF_DELAY || gifOptions.Delays != nil {
+		t.Fatalf("default options %+v", gifOptions)
+	}
+}
+
+func TestExportAnimatedGIFNegativeDelay(t *testing.T) {
+	frames := make([]interface{}, 0)
+	for _, frame := range shiftedFrames(3) {
+		data := js.Global().Get("Uint8Array").New(len(frame.Data))
+		js.CopyBytesToJS(data, frame.Data)
+		frames = append(frames, map[string]interface{}{"data": data, "width": frame.Width, "height": frame.Height})
+	}
+	result := exportAnimatedGIF(js.Undefined(), []js.Value{
+		js.ValueOf(frames),
+		js.ValueOf(map[string]interface{}{"colors": 8, "delay": -100}),
+	}).(js.Value)
+	if result.Type() != js.TypeObject {
+		t.Fatalf("exportAnimatedGIF returned %v", result)
+	}
+
+	encoded := make([]byte, result.Length())
+	js.CopyBytesToGo(encoded, result)
+	decoded, err := gif.DecodeAll(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Image) != 3 || !equalInts(decoded.Delay, []int{0, 0, 0}) {
+		t.Fatalf("%d frames, delays %v", len(decoded.Image), decoded.Delay)
+	}
+}
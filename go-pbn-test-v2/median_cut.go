@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sort"
+)
+
+// MedianCutQuantizer 中位切分量化器
+type MedianCutQuantizer struct {
+	base *Quantizer // 提供采样、像素映射、透明度和锁定颜色处理，不使用其中的 Wu 直方图
+}
+
+// NewMedianCutQuantizer 创建一个新的 MedianCutQuantizer 实例
+func NewMedianCutQuantizer(bitmap *Bitmap, colors int, options QuantizerOptions) *MedianCutQuantizer {
+	return &MedianCutQuantizer{base: newSampledQuantizer(bitmap, colors, options)}
+}
+
+// BuildPalette 构建调色板
+func (mq *MedianCutQuantizer) BuildPalette() [][4]uint8 {
+	samples := mq.base.collectSamples()
+	return mq.base.assemblePalette(func(colors int) [][4]uint8 {
+		return mq.base.samplesToPalette(medianCut(samples, colors))
+	})
+}
+
+// MapPixels 将像素映射到调色板
+func (mq *MedianCutQuantizer) MapPixels() *ColorMap {
+	return mq.base.MapPixels()
+}
+
+// Quantize 执行量化并返回量化后的 Bitmap
+func (mq *MedianCutQuantizer) Quantize() *Bitmap {
+	mq.BuildPalette()
+	return mq.MapPixels().ToImage()
+}
+
+// medianCutBox 中位切分中的一个颜色盒子
+type medianCutBox struct {
+	samples  []colorSample
+	min, max [4]float64
+	count    float64
+}
+
+// newMedianCutBox 创建包含指定颜色的盒子并计算边界
+func newMedianCutBox(samples []colorSample) *medianCutBox {
+	box := &medianCutBox{samples: samples}
+	for i, sample := range samples {
+		for c := 0; c < 4; c++ {
+			if i == 0 || sample.color[c] < box.min[c] {
+				box.min[c] = sample.color[c]
+			}
+			if i == 0 || sample.color[c] > box.max[c] {
+				box.max[c] = sample.color[c]
+			}
+		}
+		box.count += sample.count
+	}
+	return box
+}
+
+// longestAxis 返回盒子最长边的维度及长度
+func (box *medianCutBox) longestAxis() (int, float64) {
+	axis := 0
+	length := box.max[0] - box.min[0]
+	for c := 1; c < 4; c++ {
+		if l := box.max[c] - box.min[c]; l > length {
+			axis = c
+			length = l
+		}
+	}
+	return axis, length
+}
+
+// mean 计算盒子内颜色的加权平均值
+func (box *medianCutBox) mean() [4]float64 {
+	var sum [4]float64
+	for _, sample := range box.samples {
+		for c := 0; c < 4; c++ {
+			sum[c] += sample.color[c] * sample.count
+		}
+	}
+	for c := 0; c < 4; c++ {
+		sum[c] /= box.count
+	}
+	return sum
+}
+
+// split 在最长边上按像素数的中位数将盒子分成两个
+func (box *medianCutBox) split() (*medianCutBox, *medianCutBox) {
+	axis, _ := box.longestAxis()
+	sort.Slice(box.samples, func(i, j int) bool {
+		return box.samples[i].color[axis] < box.samples[j].color[axis]
+	})
+
+	// 两个盒子都至少包含一种颜色
+	half := box.count / 2
+	accumulated := 0.0
+	median := 1
+	for i := 0; i < len(box.samples)-1; i++ {
+		accumulated += box.samples[i].count
+		median = i + 1
+		if accumulated >= half {
+			break
+		}
+	}
+	return newMedianCutBox(box.samples[:median]), newMedianCutBox(box.samples[median:])
+}
+
+// medianCut 将颜色反复切分为最多 colors 个盒子，返回每个盒子的平均颜色
+// 每次切分最长边的平方与像素数之积最大的盒子，使大面积且分布宽的颜色优先获得更多调色板位置
+func medianCut(samples []colorSample, colors int) [][4]float64 {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	boxes := []*medianCutBox{newMedianCutBox(samples)}
+	for len(boxes) < colors {
+		next := -1
+		priority := 0.0
+		for i, box := range boxes {
+			if len(box.samples) < 2 {
+				continue
+			}
+			_, length := box.longestAxis()
+			if p := length * length * box.count; p > priority {
+				next = i
+				priority = p
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		first, second := boxes[next].split()
+		boxes[next] = first
+		boxes = append(boxes, second)
+	}
+
+	result := make([][4]float64, len(boxes))
+	for i, box := range boxes {
+		result[i] = box.mean()
+	}
+	return result
+}
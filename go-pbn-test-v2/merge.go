@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+)
+
+// DEFAULT_MERGE_DELTA_E 默认的合并阈值，CIEDE2000 色差小于该值的颜色在印刷或调色后难以区分
+const DEFAULT_MERGE_DELTA_E = 3.0
+
+// MergeOptions 合并相近颜色的选项
+type MergeOptions struct {
+	ColorSpace  int       // 计算加权平均值时使用的工作颜色空间
+	LockedCount int       // 调色板开头的锁定颜色数，锁定颜色保持不变且彼此之间不合并
+	Fixed       bool      // 调色板为固定色卡，颜色只能并入权重较大的已有颜色，不取平均值
+	Weights     []float64 // 与调色板对齐的权重，长度与调色板不同时使用 ColorMap 中的像素数
+}
+
+// MergeOptions 返回合并该量化器生成的 ColorMap 时使用的选项，权重为 Wu 立方体等算法记录的像素数
+func (q *Quantizer) MergeOptions() MergeOptions {
+	return MergeOptions{
+		ColorSpace:  q.ColorSpace,
+		LockedCount: len(q.LockedColors),
+		Fixed:       q.fixedPalette,
+		Weights:     q.paletteWeights,
+	}
+}
+
+// MergeSimilarColors 合并调色板中 CIEDE2000 色差小于 threshold 的颜色，并重新索引 ColorMap
+// 每次合并距离最近的一对颜色，合并后的颜色为工作颜色空间中按权重的加权平均值；
+// 固定色卡中的颜色不能取平均值，较轻的颜色并入较重的颜色（权重相同时保留索引较小的颜色）。
+// 锁定颜色保持不变且彼此之间不合并，完全透明的颜色不参与合并。返回旧索引到新索引的映射
+func MergeSimilarColors(colorMap *ColorMap, threshold float64, options MergeOptions) []uint8 {
+	count := len(colorMap.Colors)
+	remap := make([]uint8, count)
+	for i := range remap {
+		remap[i] = uint8(i)
+	}
+	if threshold <= 0 || count < 2 {
+		return remap
+	}
+
+	weights := options.Weights
+	if len(weights) != count {
+		weights = make([]float64, count)
+		for _, index := range colorMap.MappedIndices.Data {
+			if int(index) < count {
+				weights[index]++
+			}
+		}
+	} else {
+		weights = append([]float64{}, weights...)
+	}
+
+	colors := append([][4]uint8{}, colorMap.Colors...)
+	working := make([][4]float64, count)
+	labs := make([][3]float64, count)
+	for i, color := range colors {
+		c := toWorkingSpace(options.ColorSpace, color[0], color[1], color[2])
+		working[i] = [4]float64{c[0], c[1], c[2], float64(color[3])}
+		labs[i] = rgbToLab(color[0], color[1], color[2])
+	}
+
+	locked := options.LockedCount
+
+	// 每个颜色所属的组，组号为组内保留下来的颜色的索引
+	group := make([]int, count)
+	for i := range group {
+		group[i] = i
+	}
+	active := make([]bool, count)
+	for i := range active {
+		active[i] = colors[i][3] != 0
+	}
+
+	distances := make([][]float64, count)
+	for i := range distances {
+		distances[i] = make([]float64, count)
+	}
+	updateDistances := func(i int) {
+		for j := 0; j < count; j++ {
+			if j == i || !active[j] {
+				continue
+			}
+			d := math.Inf(1)
+			if i >= locked || j >= locked {
+				d = deltaE2000(labs[i], labs[j])
+			}
+			distances[i][j] = d
+			distances[j][i] = d
+		}
+	}
+	for i := 0; i < count; i++ {
+		if active[i] {
+			updateDistances(i)
+		}
+	}
+
+	for {
+		first, second := -1, -1
+		minDistance := threshold
+		for i := 0; i < count; i++ {
+			if !active[i] {
+				continue
+			}
+			for j := i + 1; j < count; j++ {
+				if active[j] && distances[i][j] < minDistance {
+					first, second = i, j
+					minDistance = distances[i][j]
+				}
+			}
+		}
+		if first < 0 {
+			break
+		}
+
+		keep, drop := first, second
+		switch {
+		case first < locked:
+			// 锁定颜色位于调色板开头，合并到索引较小的颜色即可保留锁定颜色本身
+		case options.Fixed:
+			if weights[second] > weights[first] {
+				keep, drop = second, first
+			}
+		default:
+			total := weights[first] + weights[second]
+			wa, wb := 0.5, 0.5
+			if total > 0 {
+				wa, wb = weights[first]/total, weights[second]/total
+			}
+			var mean [4]float64
+			for c := 0; c < 4; c++ {
+				mean[c] = working[first][c]*wa + working[second][c]*wb
+			}
+			working[first] = mean
+			rgb := fromWorkingSpace(options.ColorSpace, [3]float64{mean[0], mean[1], mean[2]})
+			colors[first] = [4]uint8{rgb[0], rgb[1], rgb[2], clampToUint8(mean[3])}
+			labs[first] = rgbToLab(colors[first][0], colors[first][1], colors[first][2])
+		}
+		weights[keep] += weights[drop]
+		active[drop] = false
+		for i := range group {
+			if group[i] == drop {
+				group[i] = keep
+			}
+		}
+		updateDistances(keep)
+	}
+
+	// 删除被合并的颜色并压缩索引
+	merged := make([][4]uint8, 0, count)
+	newIndex := make([]uint8, count)
+	for i := 0; i < count; i++ {
+		if group[i] == i {
+			newIndex[i] = uint8(len(merged))
+			merged = append(merged, colors[i])
+		}
+	}
+	for i := range remap {
+		remap[i] = newIndex[group[i]]
+	}
+	colorMap.RemapColors(merged, remap)
+	return remap
+}
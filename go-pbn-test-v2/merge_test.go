@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// weightedColorMap 创建一行像素的 ColorMap，第 i 种颜色占 counts[i] 个像素
+func weightedColorMap(colors [][4]uint8, counts []int) *ColorMap {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	colorMap := NewColorMap(uint32(total), 1, append([][4]uint8{}, colors...))
+	pos := 0
+	for i, count := range counts {
+		for j := 0; j < count; j++ {
+			colorMap.MappedIndices.Data[pos] = uint8(i)
+			pos++
+		}
+	}
+	return colorMap
+}
+
+// checkMerge 检查合并后的调色板、映射和像素索引
+func checkMerge(t *testing.T, colorMap *ColorMap, before []uint8, remap []uint8, wantColors [][4]uint8, wantRemap []uint8) {
+	t.Helper()
+	if !samePalette(colorMap.Colors, wantColors) {
+		t.Fatalf("colors %v, want %v", colorMap.Colors, wantColors)
+	}
+	if !bytes.Equal(remap, wantRemap) {
+		t.Fatalf("remap %v, want %v", remap, wantRemap)
+	}
+	for i, index := range colorMap.MappedIndices.Data {
+		if index != wantRemap[before[i]] {
+			t.Fatalf("pixel %d: index %d, want %d", i, index, wantRemap[before[i]])
+		}
+	}
+}
+
+var mergePalette = [][4]uint8{{100, 100, 100, 255}, {106, 106, 106, 255}, {255, 0, 0, 255}, {0, 0, 0, 0}}
+
+func TestMergeSimilarColorsThreshold(t *testing.T) {
+	colorMap := weightedColorMap(mergePalette, []int{3, 1, 2, 2})
+	before := append([]uint8{}, colorMap.MappedIndices.Data...)
+	remap := MergeSimilarColors(colorMap, 1, MergeOptions{})
+	checkMerge(t, colorMap, before, remap, mergePalette, []uint8{0, 1, 2, 3})
+
+	// 按像素数 3:1 加权平均
+	remap = MergeSimilarColors(colorMap, DEFAULT_MERGE_DELTA_E+1, MergeOptions{})
+	checkMerge(t, colorMap, before, remap,
+		[][4]uint8{{102, 102, 102, 255}, {255, 0, 0, 255}, {0, 0, 0, 0}}, []uint8{0, 0, 1, 2})
+}
+
+func TestMergeSimilarColorsFixedPalette(t *testing.T) {
+	// 色卡中的颜色不取平均值，并入像素较多的颜色
+	colorMap := weightedColorMap(mergePalette, []int{1, 3, 2, 2})
+	before := append([]uint8{}, colorMap.MappedIndices.Data...)
+	remap := MergeSimilarColors(colorMap, DEFAULT_MERGE_DELTA_E+1, MergeOptions{Fixed: true})
+	checkMerge(t, colorMap, before, remap,
+		[][4]uint8{{106, 106, 106, 255}, {255, 0, 0, 255}, {0, 0, 0, 0}}, []uint8{0, 0, 1, 2})
+
+	// 通过 Quantizer 使用固定调色板时同样如此
+	bmp := NewBitmap(4, 1)
+	for i, color := range [][4]uint8{{100, 100, 100, 255}, {106, 106, 106, 255}, {106, 106, 106, 255}, {0, 0, 0, 0}} {
+		copy(bmp.Data[i*4:], color[:])
+	}
+	options := DefaultQuantizerOptions()
+	options.Alpha.Threshold = 128
+	quantizer := NewQuantizerWithPalette(bmp, mergePalette[:3], options)
+	quantizer.BuildPalette()
+	colorMap = quantizer.MapPixels()
+	MergeSimilarColors(colorMap, DEFAULT_MERGE_DELTA_E+1, quantizer.MergeOptions())
+	for _, color := range colorMap.Colors {
+		if color != mergePalette[1] && color != mergePalette[2] && color != mergePalette[3] {
+			t.Fatalf("merged color %v is not in the catalog", color)
+		}
+	}
+}
+
+func TestMergeSimilarColorsLocked(t *testing.T) {
+	// 两个锁定颜色彼此之间不合并，相近的自适应颜色并入锁定颜色且不改变锁定颜色
+	colors := [][4]uint8{{100, 100, 100, 255}, {103, 103, 103, 255}, {101, 101, 101, 255}, {255, 0, 0, 255}}
+	colorMap := weightedColorMap(colors, []int{1, 1, 10, 2})
+	before := append([]uint8{}, colorMap.MappedIndices.Data...)
+	remap := MergeSimilarColors(colorMap, DEFAULT_MERGE_DELTA_E+1, MergeOptions{LockedCount: 2})
+	checkMerge(t, colorMap, before, remap,
+		[][4]uint8{{100, 100, 100, 255}, {103, 103, 103, 255}, {255, 0, 0, 255}}, []uint8{0, 1, 0, 2})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+)
+
+// SSIM 计算参数
+const (
+	SSIM_WINDOW = 8                           // 滑动窗口边长
+	SSIM_C1     = (0.01 * 255) * (0.01 * 255) // 亮度稳定常数
+	SSIM_C2     = (0.03 * 255) * (0.03 * 255) // 对比度稳定常数
+)
+
+// QualityMetrics 量化结果与原图的比较结果
+// 映射到完全透明颜色的像素是有意去除的，不参与统计
+type QualityMetrics struct {
+	PixelCount int     // 参与统计的像素数
+	MSE        float64 // RGB 分量的均方误差
+	PSNR       float64 // 峰值信噪比（dB），完全一致时为 +Inf
+	SSIM       float64 // 亮度的结构相似度，1 表示完全一致
+	MeanDeltaE float64 // 平均 CIEDE2000 色差
+	MaxDeltaE  float64 // 最大 CIEDE2000 色差
+	Entries    []PaletteEntryMetrics
+}
+
+// PaletteEntryMetrics 单个调色板颜色的统计
+type PaletteEntryMetrics struct {
+	PixelCount   int     // 映射到该颜色的像素数
+	SquaredError float64 // 这些像素的 RGB 误差平方和
+	ErrorShare   float64 // 在总误差中所占的比例
+	MeanDeltaE   float64 // 这些像素的平均 CIEDE2000 色差
+}
+
+// ComputeQualityMetrics 比较原图与 ColorMap.ToImage 的结果
+// 量化后的颜色直接从调色板读取，不生成整幅的量化图像
+func ComputeQualityMetrics(original *Bitmap, colorMap *ColorMap) *QualityMetrics {
+	metrics := &QualityMetrics{
+		Entries: make([]PaletteEntryMetrics, len(colorMap.Colors)),
+	}
+
+	paletteLab := make([][3]float64, len(colorMap.Colors))
+	for i, color := range colorMap.Colors {
+		paletteLab[i] = rgbToLab(color[0], color[1], color[2])
+	}
+
+	totalSquaredError := 0.0
+	totalDeltaE := 0.0
+	deltaESums := make([]float64, len(colorMap.Colors))
+	for i, index := range colorMap.MappedIndices.Data {
+		pos := i * 4
+		if pos+3 >= len(original.Data) {
+			break
+		}
+		if int(index) >= len(colorMap.Colors) || colorMap.Colors[index][3] == 0 {
+			continue
+		}
+
+		color := colorMap.Colors[index]
+		squaredError := 0.0
+		for c := 0; c < 3; c++ {
+			d := float64(original.Data[pos+c]) - float64(color[c])
+			squaredError += d * d
+		}
+		deltaE := deltaE2000(rgbToLab(original.Data[pos], original.Data[pos+1], original.Data[pos+2]), paletteLab[index])
+
+		entry := &metrics.Entries[index]
+		entry.PixelCount++
+		entry.SquaredError += squaredError
+		deltaESums[index] += deltaE
+
+		metrics.PixelCount++
+		totalSquaredError += squaredError
+		totalDeltaE += deltaE
+		if deltaE > metrics.MaxDeltaE {
+			metrics.MaxDeltaE = deltaE
+		}
+	}
+
+	if metrics.PixelCount > 0 {
+		metrics.MSE = totalSquaredError / float64(metrics.PixelCount*3)
+		metrics.MeanDeltaE = totalDeltaE / float64(metrics.PixelCount)
+	}
+	metrics.PSNR = math.Inf(1)
+	if metrics.MSE > 0 {
+		metrics.PSNR = 10 * math.Log10(255*255/metrics.MSE)
+	}
+	for i := range metrics.Entries {
+		entry := &metrics.Entries[i]
+		if entry.PixelCount > 0 {
+			entry.MeanDeltaE = deltaESums[i] / float64(entry.PixelCount)
+		}
+		if totalSquaredError > 0 {
+			entry.ErrorShare = entry.SquaredError / totalSquaredError
+		}
+	}
+
+	metrics.SSIM = computeSSIM(original, colorMap)
+	return metrics
+}
+
+// computeSSIM 计算原图与量化结果亮度分量在所有滑动窗口上的平均结构相似度
+// 映射到完全透明颜色或无效索引的像素使用原图的亮度，不影响结果
+// 按列保存最近 window 行的滚动和，再在每一行上水平滑动，内存只与图像宽度成正比
+func computeSSIM(original *Bitmap, colorMap *ColorMap) float64 {
+	width, height := int(original.Width), int(original.Height)
+	if width == 0 || height == 0 || len(original.Data) < width*height*4 || len(colorMap.MappedIndices.Data) < width*height {
+		return 0
+	}
+	window := SSIM_WINDOW
+	if width < window {
+		window = width
+	}
+	if height < window {
+		window = height
+	}
+
+	paletteLuminance := make([]float64, len(colorMap.Colors))
+	for i, color := range colorMap.Colors {
+		paletteLuminance[i] = luminance(color[0], color[1], color[2])
+	}
+
+	// 最近 window 行的亮度按环形缓冲保存，离开窗口的行从列和中减去
+	rowsX := make([]float64, window*width)
+	rowsY := make([]float64, window*width)
+	colX := make([]float64, width)
+	colY := make([]float64, width)
+	colXX := make([]float64, width)
+	colYY := make([]float64, width)
+	colXY := make([]float64, width)
+
+	area := float64(window * window)
+	total := 0.0
+	count := 0
+	for y := 0; y < height; y++ {
+		slot := (y % window) * width
+		for x := 0; x < width; x++ {
+			if y >= window {
+				lx, ly := rowsX[slot+x], rowsY[slot+x]
+				colX[x] -= lx
+				colY[x] -= ly
+				colXX[x] -= lx * lx
+				colYY[x] -= ly * ly
+				colXY[x] -= lx * ly
+			}
+
+			pos := (y*width + x) * 4
+			lx := luminance(original.Data[pos], original.Data[pos+1], original.Data[pos+2])
+			ly := lx
+			index := colorMap.MappedIndices.Data[y*width+x]
+			if int(index) < len(colorMap.Colors) && colorMap.Colors[index][3] != 0 {
+				ly = paletteLuminance[index]
+			}
+			rowsX[slot+x], rowsY[slot+x] = lx, ly
+			colX[x] += lx
+			colY[x] += ly
+			colXX[x] += lx * lx
+			colYY[x] += ly * ly
+			colXY[x] += lx * ly
+		}
+		if y+1 < window {
+			continue
+		}
+
+		var sumX, sumY, sumXX, sumYY, sumXY float64
+		for x := 0; x < width; x++ {
+			sumX += colX[x]
+			sumY += colY[x]
+			sumXX += colXX[x]
+			sumYY += colYY[x]
+			sumXY += colXY[x]
+			if x >= window {
+				sumX -= colX[x-window]
+				sumY -= colY[x-window]
+				sumXX -= colXX[x-window]
+				sumYY -= colYY[x-window]
+				sumXY -= colXY[x-window]
+			}
+			if x+1 < window {
+				continue
+			}
+
+			meanX := sumX / area
+			meanY := sumY / area
+			varianceX := sumXX/area - meanX*meanX
+			varianceY := sumYY/area - meanY*meanY
+			covariance := sumXY/area - meanX*meanY
+
+			total += ((2*meanX*meanY + SSIM_C1) * (2*covariance + SSIM_C2)) /
+				((meanX*meanX + meanY*meanY + SSIM_C1) * (varianceX + varianceY + SSIM_C2))
+			count++
+		}
+	}
+	return total / float64(count)
+}
+
+// luminance 计算 sRGB 颜色的亮度（ITU-R BT.601 权重）
+func luminance(r, g, b uint8) float64 {
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
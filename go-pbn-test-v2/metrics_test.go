@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// naiveSSIM 逐个窗口直接求和计算平均 SSIM，用于校验 computeSSIM
+func naiveSSIM(original *Bitmap, colorMap *ColorMap) float64 {
+	width, height := int(original.Width), int(original.Height)
+	window := min(SSIM_WINDOW, width, height)
+	area := float64(window * window)
+	total := 0.0
+	count := 0
+	for top := 0; top+window <= height; top++ {
+		for left := 0; left+window <= width; left++ {
+			var sumX, sumY, sumXX, sumYY, sumXY float64
+			for y := top; y < top+window; y++ {
+				for x := left; x < left+window; x++ {
+					pos := (y*width + x) * 4
+					lx := luminance(original.Data[pos], original.Data[pos+1], original.Data[pos+2])
+					ly := lx
+					if color := colorMap.Colors[colorMap.MappedIndices.Data[y*width+x]]; color[3] != 0 {
+						ly = luminance(color[0], color[1], color[2])
+					}
+					sumX += lx
+					sumY += ly
+					sumXX += lx * lx
+					sumYY += ly * ly
+					sumXY += lx * ly
+				}
+			}
+			meanX, meanY := sumX/area, sumY/area
+			varianceX := sumXX/area - meanX*meanX
+			varianceY := sumYY/area - meanY*meanY
+			covariance := sumXY/area - meanX*meanY
+			total += ((2*meanX*meanY + SSIM_C1) * (2*covariance + SSIM_C2)) /
+				((meanX*meanX + meanY*meanY + SSIM_C1) * (varianceX + varianceY + SSIM_C2))
+			count++
+		}
+	}
+	return total / float64(count)
+}
+
+func TestComputeSSIMMatchesNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range [][2]uint32{{37, 23}, {5, 30}, {30, 3}, {1, 1}} {
+		bmp := NewBitmap(size[0], size[1])
+		for i := range bmp.Data {
+			bmp.Data[i] = uint8(rng.Intn(256))
+		}
+		options := DefaultQuantizerOptions()
+		options.Alpha.Threshold = 64
+		quantizer := NewQuantizerWithOptions(bmp, 12, options)
+		quantizer.BuildPalette()
+		colorMap := quantizer.MapPixels()
+
+		got := computeSSIM(bmp, colorMap)
+		want := naiveSSIM(bmp, colorMap)
+		if math.Abs(got-want) > 1e-9 {
+			t.Fatalf("%dx%d: SSIM = %v, want %v", size[0], size[1], got, want)
+		}
+	}
+}
+
+func TestQualityMetricsIdentical(t *testing.T) {
+	colors := [][4]uint8{{255, 0, 0, 255}, {0, 0, 255, 255}, {0, 0, 0, 0}}
+	colorMap := NewColorMap(16, 12, colors)
+	bmp := NewBitmap(16, 12)
+	for i := range colorMap.MappedIndices.Data {
+		index := uint8((i / 5) % len(colors))
+		colorMap.MappedIndices.Data[i] = index
+		copy(bmp.Data[i*4:], colors[index][:])
+		if colors[index][3] == 0 {
+			// 映射到透明颜色的像素不参与统计，原图颜色任意
+			copy(bmp.Data[i*4:], []uint8{12, 200, 7, 255})
+		}
+	}
+
+	metrics := ComputeQualityMetrics(bmp, colorMap)
+	if metrics.MSE != 0 || !math.IsInf(metrics.PSNR, 1) || metrics.MaxDeltaE != 0 {
+		t.Fatalf("MSE = %v, PSNR = %v, MaxDeltaE = %v", metrics.MSE, metrics.PSNR, metrics.MaxDeltaE)
+	}
+	if math.Abs(metrics.SSIM-1) > 1e-9 {
+		t.Fatalf("SSIM = %v, want 1", metrics.SSIM)
+	}
+	if metrics.PixelCount != metrics.Entries[0].PixelCount+metrics.Entries[1].PixelCount || metrics.Entries[2].PixelCount != 0 {
+		t.Fatalf("pixel counts %d %+v", metrics.PixelCount, metrics.Entries)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"math"
+)
+
+// NeuQuant 学习参数，参考 Anthony Dekker 的原始实现
+const (
+	NEUQUANT_SAMPLE_FACTOR = 10  // 采样因子，每 N 个像素学习一次，1 为全部学习
+	NEUQUANT_CYCLES        = 100 // 学习率和邻域半径的衰减次数
+	NEUQUANT_RADIUS_DEC    = 30  // 每次衰减时邻域半径减小 1/30
+	NEUQUANT_BETA          = 1.0 / 1024.0
+	NEUQUANT_GAMMA         = 1024.0
+	NEUQUANT_MIN_SAMPLES   = 1500 // 参与学习的像素较少时全部学习
+)
+
+// 采样步长使用的质数，像素数不能被其整除时保证遍历到所有位置
+var neuQuantPrimes = [4]int{499, 491, 487, 503}
+
+// NeuQuantQuantizer NeuQuant 神经网络量化器
+type NeuQuantQuantizer struct {
+	base *Quantizer // 提供采样、像素映射、透明度和锁定颜色处理，不使用其中的 Wu 直方图
+}
+
+// NewNeuQuantQuantizer 创建一个新的 NeuQuantQuantizer 实例
+func NewNeuQuantQuantizer(bitmap *Bitmap, colors int, options QuantizerOptions) *NeuQuantQuantizer {
+	return &NeuQuantQuantizer{base: newSampledQuantizer(bitmap, colors, options)}
+}
+
+// BuildPalette 构建调色板
+func (nq *NeuQuantQuantizer) BuildPalette() [][4]uint8 {
+	return nq.base.assemblePalette(func(colors int) [][4]uint8 {
+		return nq.base.samplesToPalette(nq.learn(colors))
+	})
+}
+
+// MapPixels 将像素映射到调色板
+func (nq *NeuQuantQuantizer) MapPixels() *ColorMap {
+	return nq.base.MapPixels()
+}
+
+// Quantize 执行量化并返回量化后的 Bitmap
+func (nq *NeuQuantQuantizer) Quantize() *Bitmap {
+	nq.BuildPalette()
+	return nq.MapPixels().ToImage()
+}
+
+// neuQuantNetwork 自组织映射网络
+type neuQuantNetwork struct {
+	neurons [][4]float64
+	freq    []float64
+	bias    []float64
+	wins    []int // 每个神经元获胜的次数
+}
+
+// newNeuQuantNetwork 创建网络，神经元初始均匀分布在从黑到白的灰色上
+func newNeuQuantNetwork(colorSpace, size int) *neuQuantNetwork {
+	network := &neuQuantNetwork{
+		neurons: make([][4]float64, size),
+		freq:    make([]float64, size),
+		bias:    make([]float64, size),
+		wins:    make([]int, size),
+	}
+	for i := range network.neurons {
+		v := uint8(i * 256 / size)
+		c := toWorkingSpace(colorSpace, v, v, v)
+		network.neurons[i] = [4]float64{c[0], c[1], c[2], 255}
+		network.freq[i] = 1.0 / float64(size)
+	}
+	return network
+}
+
+// contest 查找与颜色最接近的神经元并更新频率偏置，返回考虑偏置后的获胜神经元
+// 偏置使很少获胜的神经元更容易被选中，避免出现不被使用的颜色
+func (n *neuQuantNetwork) contest(color [4]float64) int {
+	bestDistance := math.MaxFloat64
+	bestBiasDistance := math.MaxFloat64
+	bestPos := 0
+	bestBiasPos := 0
+
+	for i, neuron := range n.neurons {
+		distance := math.Abs(neuron[0]-color[0]) + math.Abs(neuron[1]-color[1]) +
+			math.Abs(neuron[2]-color[2]) + math.Abs(neuron[3]-color[3])
+		if distance < bestDistance {
+			bestDistance = distance
+			bestPos = i
+		}
+		if biasDistance := distance - n.bias[i]; biasDistance < bestBiasDistance {
+			bestBiasDistance = biasDistance
+			bestBiasPos = i
+		}
+
+		betaFreq := n.freq[i] * NEUQUANT_BETA
+		n.freq[i] -= betaFreq
+		n.bias[i] += betaFreq * NEUQUANT_GAMMA
+	}
+
+	n.freq[bestPos] += NEUQUANT_BETA
+	n.bias[bestPos] -= NEUQUANT_BETA * NEUQUANT_GAMMA
+	n.wins[bestBiasPos]++
+	return bestBiasPos
+}
+
+// alter 将神经元向颜色移动 alpha 比例
+func (n *neuQuantNetwork) alter(i int, alpha float64, color [4]float64) {
+	for c := 0; c < 4; c++ {
+		n.neurons[i][c] -= alpha * (n.neurons[i][c] - color[c])
+	}
+}
+
+// alterNeighbours 以随距离递减的比例移动获胜神经元两侧 radius 范围内的神经元
+func (n *neuQuantNetwork) alterNeighbours(center, radius int, alpha float64, color [4]float64) {
+	for d := 1; d < radius; d++ {
+		a := alpha * float64(radius*radius-d*d) / float64(radius*radius)
+		if i := center - d; i >= 0 {
+			n.alter(i, a, color)
+		}
+		if i := center + d; i < len(n.neurons) {
+			n.alter(i, a, color)
+		}
+	}
+}
+
+// learn 以质数步长遍历像素训练网络，返回获胜过的神经元颜色
+func (nq *NeuQuantQuantizer) learn(colors int) [][4]float64 {
+	pixelCount := len(nq.base.Bitmap.Data) / 4
+	participating := 0
+	for i := 0; i < pixelCount; i++ {
+		if _, ok := nq.base.pixelWorking(i); ok {
+			participating++
+		}
+	}
+	if participating == 0 {
+		return nil
+	}
+
+	sampleFactor := NEUQUANT_SAMPLE_FACTOR
+	if participating < NEUQUANT_MIN_SAMPLES {
+		sampleFactor = 1
+	}
+	samplePixels := participating / sampleFactor
+	delta := samplePixels / NEUQUANT_CYCLES
+	if delta < 1 {
+		delta = 1
+	}
+	alphaDec := 30.0 + float64(sampleFactor-1)/3.0
+
+	step := neuQuantPrimes[len(neuQuantPrimes)-1]
+	for _, prime := range neuQuantPrimes {
+		if pixelCount%prime != 0 {
+			step = prime
+			break
+		}
+	}
+
+	network := newNeuQuantNetwork(nq.base.ColorSpace, colors)
+	alpha := 1.0
+	radius := float64(colors) / 8
+	rad := int(radius)
+	if rad <= 1 {
+		rad = 0
+	}
+
+	pos := 0
+	for learned := 0; learned < samplePixels; {
+		color, ok := nq.base.pixelWorking(pos)
+		pos = (pos + step) % pixelCount
+		if !ok {
+			continue
+		}
+
+		winner := network.contest(color)
+		network.alter(winner, alpha, color)
+		if rad > 0 {
+			network.alterNeighbours(winner, rad, alpha, color)
+		}
+
+		learned++
+		if learned%delta == 0 {
+			alpha -= alpha / alphaDec
+			radius -= radius / NEUQUANT_RADIUS_DEC
+			rad = int(radius)
+			if rad <= 1 {
+				rad = 0
+			}
+		}
+	}
+
+	// 颜色比神经元少时部分神经元从未获胜，不加入调色板
+	result := make([][4]float64, 0, colors)
+	for i, neuron := range network.neurons {
+		if network.wins[i] > 0 {
+			result = append(result, neuron)
+		}
+	}
+	return result
+}
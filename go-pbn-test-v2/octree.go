@@ -0,0 +1,223 @@
+package main
+
+const (
+	OCTREE_DEPTH      = 8    // 八叉树深度，每层对应颜色分量的一位
+	OCTREE_MAX_LEAVES = 4096 // 插入过程中允许的最大叶子数，超过时合并节点以限制内存
+)
+
+// OctreeQuantizer 八叉树量化器
+type OctreeQuantizer struct {
+	base *Quantizer // 提供采样、像素映射、透明度和锁定颜色处理，不使用其中的 Wu 直方图
+}
+
+// NewOctreeQuantizer 创建一个新的 OctreeQuantizer 实例
+func NewOctreeQuantizer(bitmap *Bitmap, colors int, options QuantizerOptions) *OctreeQuantizer {
+	return &OctreeQuantizer{base: newSampledQuantizer(bitmap, colors, options)}
+}
+
+// BuildPalette 构建调色板
+func (oq *OctreeQuantizer) BuildPalette() [][4]uint8 {
+	samples := oq.base.collectSamples()
+	return oq.base.assemblePalette(func(colors int) [][4]uint8 {
+		tree := newOctree()
+		for _, sample := range samples {
+			tree.insert(sample)
+			for tree.leafCount > OCTREE_MAX_LEAVES {
+				tree.reduce(OCTREE_MAX_LEAVES)
+			}
+		}
+		for tree.leafCount > colors {
+			tree.reduce(colors)
+		}
+		return oq.base.samplesToPalette(tree.colors())
+	})
+}
+
+// MapPixels 将像素映射到调色板
+func (oq *OctreeQuantizer) MapPixels() *ColorMap {
+	return oq.base.MapPixels()
+}
+
+// Quantize 执行量化并返回量化后的 Bitmap
+func (oq *OctreeQuantizer) Quantize() *Bitmap {
+	oq.BuildPalette()
+	return oq.MapPixels().ToImage()
+}
+
+// octreeNode 八叉树节点
+type octreeNode struct {
+	children [8]*octreeNode
+	leaf     bool
+	count    float64
+	sum      [4]float64
+}
+
+// octree 颜色八叉树
+type octree struct {
+	root      *octreeNode
+	reducible [OCTREE_DEPTH][]*octreeNode // 每一层中含有子节点的节点
+	leafCount int
+}
+
+// newOctree 创建一个空的八叉树
+func newOctree() *octree {
+	return &octree{root: &octreeNode{}}
+}
+
+// insert 将颜色插入八叉树，遇到叶子节点时累加到该节点
+func (t *octree) insert(sample colorSample) {
+	r := clampToUint8(sample.color[0])
+	g := clampToUint8(sample.color[1])
+	b := clampToUint8(sample.color[2])
+
+	node := t.root
+	for level := 0; !node.leaf; level++ {
+		if level == OCTREE_DEPTH {
+			node.leaf = true
+			t.leafCount++
+			break
+		}
+
+		shift := 7 - level
+		index := (r>>shift&1)<<2 | (g>>shift&1)<<1 | (b >> shift & 1)
+		child := node.children[index]
+		if child == nil {
+			child = &octreeNode{}
+			node.children[index] = child
+			if !hasOtherChildren(node, index) {
+				t.reducible[level] = append(t.reducible[level], node)
+			}
+		}
+		node = child
+	}
+
+	node.count += sample.count
+	for c := 0; c < 4; c++ {
+		node.sum[c] += sample.color[c] * sample.count
+	}
+}
+
+// hasOtherChildren 判断节点除 index 之外是否还有子节点
+func hasOtherChildren(node *octreeNode, index uint8) bool {
+	for i, child := range node.children {
+		if uint8(i) != index && child != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// reduce 将最深一层中像素数最少的节点的所有子节点合并到该节点
+// 全部合并会使叶子数少于 target 时，只合并其中像素数最少的两个子节点
+func (t *octree) reduce(target int) {
+	level := OCTREE_DEPTH - 1
+	for level >= 0 && len(t.reducible[level]) == 0 {
+		level--
+	}
+	if level < 0 {
+		return
+	}
+
+	nodes := t.reducible[level]
+	smallest := 0
+	smallestCount := subtreeCount(nodes[0])
+	for i := 1; i < len(nodes); i++ {
+		if count := subtreeCount(nodes[i]); count < smallestCount {
+			smallest = i
+			smallestCount = count
+		}
+	}
+	node := nodes[smallest]
+
+	// 最深一层的子节点都是叶子节点
+	if children := childCount(node); children-1 > t.leafCount-target {
+		t.mergeSmallestChildren(node)
+		return
+	}
+	t.reducible[level] = append(nodes[:smallest], nodes[smallest+1:]...)
+
+	merged := 0
+	for i, child := range node.children {
+		if child == nil {
+			continue
+		}
+		node.count += child.count
+		for c := 0; c < 4; c++ {
+			node.sum[c] += child.sum[c]
+		}
+		node.children[i] = nil
+		merged++
+	}
+	node.leaf = true
+	t.leafCount -= merged - 1
+}
+
+// mergeSmallestChildren 将节点中像素数最少的两个叶子节点合并为一个
+func (t *octree) mergeSmallestChildren(node *octreeNode) {
+	first, second := -1, -1
+	for i, child := range node.children {
+		if child == nil {
+			continue
+		}
+		if first < 0 || child.count < node.children[first].count {
+			first, second = i, first
+		} else if second < 0 || child.count < node.children[second].count {
+			second = i
+		}
+	}
+
+	target, source := node.children[first], node.children[second]
+	target.count += source.count
+	for c := 0; c < 4; c++ {
+		target.sum[c] += source.sum[c]
+	}
+	node.children[second] = nil
+	t.leafCount--
+}
+
+// childCount 计算节点的子节点数
+func childCount(node *octreeNode) int {
+	count := 0
+	for _, child := range node.children {
+		if child != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// subtreeCount 计算节点的子节点中的像素数
+func subtreeCount(node *octreeNode) float64 {
+	count := 0.0
+	for _, child := range node.children {
+		if child != nil {
+			count += child.count
+		}
+	}
+	return count
+}
+
+// colors 返回所有叶子节点的平均颜色
+func (t *octree) colors() [][4]float64 {
+	result := make([][4]float64, 0, t.leafCount)
+	var walk func(node *octreeNode)
+	walk = func(node *octreeNode) {
+		if node.leaf {
+			if node.count > 0 {
+				var mean [4]float64
+				for c := 0; c < 4; c++ {
+					mean[c] = node.sum[c] / node.count
+				}
+				result = append(result, mean)
+			}
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(t.root)
+	return result
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_LOCKED_RADIUS 默认的锁定颜色覆盖半径（工作颜色空间中的欧几里得距离）
+const DEFAULT_LOCKED_RADIUS = 16.0
+
+// NewQuantizerWithPalette 使用调用方提供的固定调色板创建 Quantizer
+// 不统计直方图也不执行 Wu 算法，BuildPalette 直接返回该调色板，MapPixels 仍支持抖动等选项
+func NewQuantizerWithPalette(bitmap *Bitmap, palette [][4]uint8, options QuantizerOptions) *Quantizer {
+	if len(palette) > MAX_COLOR {
+		palette = palette[:MAX_COLOR]
+	}
+
+	fixed := make([][4]uint8, len(palette), len(palette)+1)
+	copy(fixed, palette)
+
+	quant := &Quantizer{
+		Colors:     len(fixed),
+		Palette:    fixed,
+		Bitmap:     bitmap.Clone(),
+		ColorSpace: options.ColorSpace,
+		Dither:     options.Dither,
+		Alpha:      options.Alpha,
+
+		TransparentIndex: -1,
+		fixedPalette:     true,
+	}
+
+	// 存在透明像素时在调色板末尾追加透明颜色
+	if options.Alpha.Threshold > 0 && len(quant.Palette) < MAX_COLOR {
+		for i := 3; i < len(bitmap.Data); i += 4 {
+			if bitmap.Data[i] < options.Alpha.Threshold {
+				quant.TransparentIndex = len(quant.Palette)
+				quant.Palette = append(quant.Palette, [4]uint8{0, 0, 0, 0})
+				break
+			}
+		}
+	}
+
+	return quant
+}
+
+// limitLockedColors 复制锁定颜色并限制数量，需要透明颜色时为其保留一个位置
+func limitLockedColors(colors [][4]uint8, alpha AlphaOptions) [][4]uint8 {
+	limit := MAX_COLOR
+	if alpha.Threshold > 0 {
+		limit--
+	}
+	if len(colors) > limit {
+		colors = colors[:limit]
+	}
+	return append([][4]uint8{}, colors...)
+}
+
+// normalizeColorCount 将颜色数限制在 1~MAX_COLOR，锁定颜色必须全部保留，颜色数不足时自动增加
+func normalizeColorCount(colors int, locked [][4]uint8) int {
+	if colors > MAX_COLOR {
+		colors = MAX_COLOR
+	}
+	if colors < 1 {
+		colors = 1
+	}
+	if len(locked) > colors {
+		colors = len(locked)
+	}
+	return colors
+}
+
+// lockedWorkingColors 计算锁定颜色在工作颜色空间中的坐标
+func lockedWorkingColors(colorSpace int, colors [][4]uint8) [][3]float64 {
+	working := make([][3]float64, len(colors))
+	for i, color := range colors {
+		working[i] = toWorkingSpace(colorSpace, color[0], color[1], color[2])
+	}
+	return working
+}
+
+// coveredByLockedColor 判断颜色是否已由某个锁定颜色表示
+// 这些像素不参与直方图统计，使 Wu 算法把剩余的颜色分配给锁定颜色覆盖不到的区域
+func (q *Quantizer) coveredByLockedColor(c [3]float64) bool {
+	radius := q.lockedRadius * q.lockedRadius
+	for _, locked := range q.lockedWorking {
+		d0 := c[0] - locked[0]
+		d1 := c[1] - locked[1]
+		d2 := c[2] - locked[2]
+		if d0*d0+d1*d1+d2*d2 <= radius {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHexColor 解析 #rgb、#rrggbb 或 #rrggbbaa 格式的颜色
+func ParseHexColor(value string) ([4]uint8, bool) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return [4]uint8{}, false
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return [4]uint8{}, false
+	}
+	return [4]uint8{uint8(n >> 24), uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+}
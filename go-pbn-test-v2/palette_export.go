@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 调色板导出格式
+const (
+	PALETTE_FORMAT_GPL  = iota // GIMP 调色板
+	PALETTE_FORMAT_ASE         // Adobe Swatch Exchange
+	PALETTE_FORMAT_ACO         // Photoshop 色板
+	PALETTE_FORMAT_JSON        // 包含 hex/RGB/Lab 及覆盖率的 JSON
+	PALETTE_FORMAT_CSS         // CSS 自定义属性
+)
+
+// DEFAULT_PALETTE_NAME 默认的调色板名称，也用作 CSS 变量名前缀
+const DEFAULT_PALETTE_NAME = "pbn"
+
+// ExportPalette 按指定格式导出 ColorMap 的调色板
+// 颜色名称为调色板索引，与模板中的编号一致；完全透明的颜色不是颜料，只在 JSON 中输出
+func ExportPalette(colorMap *ColorMap, format int, name string) []byte {
+	if name == "" {
+		name = DEFAULT_PALETTE_NAME
+	}
+	switch format {
+	case PALETTE_FORMAT_ASE:
+		return EncodeASE(colorMap.Colors, name)
+	case PALETTE_FORMAT_ACO:
+		return EncodeACO(colorMap.Colors)
+	case PALETTE_FORMAT_JSON:
+		return EncodePaletteJSON(colorMap, name)
+	case PALETTE_FORMAT_CSS:
+		return EncodeCSS(colorMap.Colors, name)
+	default:
+		return EncodeGPL(colorMap.Colors, name)
+	}
+}
+
+// ParsePaletteFormat 根据名称返回调色板格式，未知名称返回 PALETTE_FORMAT_GPL
+func ParsePaletteFormat(name string) int {
+	switch strings.ToLower(name) {
+	case "ase":
+		return PALETTE_FORMAT_ASE
+	case "aco":
+		return PALETTE_FORMAT_ACO
+	case "json":
+		return PALETTE_FORMAT_JSON
+	case "css":
+		return PALETTE_FORMAT_CSS
+	default:
+		return PALETTE_FORMAT_GPL
+	}
+}
+
+// IsBinaryPaletteFormat 判断格式是否为二进制格式
+func IsBinaryPaletteFormat(format int) bool {
+	return format == PALETTE_FORMAT_ASE || format == PALETTE_FORMAT_ACO
+}
+
+// EncodeGPL 编码为 GIMP .gpl 调色板
+func EncodeGPL(colors [][4]uint8, name string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "GIMP Palette\nName: %s\nColumns: 0\n#\n", name)
+	for i, color := range colors {
+		if color[3] == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "%3d %3d %3d\t%d\n", color[0], color[1], color[2], i)
+	}
+	return buf.Bytes()
+}
+
+// EncodeASE 编码为 Adobe .ase 色板交换文件，所有颜色放在以 name 命名的组中
+func EncodeASE(colors [][4]uint8, name string) []byte {
+	blocks := make([][]byte, 0, len(colors)+2)
+	blocks = append(blocks, aseBlock(0xC001, aseString(name)))
+	for i, color := range colors {
+		if color[3] == 0 {
+			continue
+		}
+		var body bytes.Buffer
+		body.Write(aseString(strconv.Itoa(i)))
+		body.WriteString("RGB ")
+		for c := 0; c < 3; c++ {
+			binary.Write(&body, binary.BigEndian, float32(color[c])/255)
+		}
+		binary.Write(&body, binary.BigEndian, uint16(2)) // 普通颜色
+		blocks = append(blocks, aseBlock(0x0001, body.Bytes()))
+	}
+	blocks = append(blocks, aseBlock(0xC002, nil))
+
+	var buf bytes.Buffer
+	buf.WriteString("ASEF")
+	binary.Write(&buf, binary.BigEndian, uint16(1)) // 版本 1.0
+	binary.Write(&buf, binary.BigEndian, uint16(0))
+	binary.Write(&buf, binary.BigEndian, uint32(len(blocks)))
+	for _, block := range blocks {
+		buf.Write(block)
+	}
+	return buf.Bytes()
+}
+
+// aseBlock 编码 ASE 数据块：类型、长度和内容
+func aseBlock(blockType uint16, body []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, blockType)
+	binary.Write(&buf, binary.BigEndian, uint32(len(body)))
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// aseString 编码 ASE 字符串：包括结尾 0 的字符数及 UTF-16BE 内容
+func aseString(value string) []byte {
+	units := append(utf16.Encode([]rune(value)), 0)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(len(units)))
+	binary.Write(&buf, binary.BigEndian, units)
+	return buf.Bytes()
+}
+
+// EncodeACO 编码为 Photoshop .aco 色板，依次写入版本 1 和带名称的版本 2
+func EncodeACO(colors [][4]uint8) []byte {
+	opaque := make([]int, 0, len(colors))
+	for i, color := range colors {
+		if color[3] != 0 {
+			opaque = append(opaque, i)
+		}
+	}
+
+	var buf bytes.Buffer
+	for version := uint16(1); version <= 2; version++ {
+		binary.Write(&buf, binary.BigEndian, version)
+		binary.Write(&buf, binary.BigEndian, uint16(len(opaque)))
+		for _, i := range opaque {
+			color := colors[i]
+			// 颜色空间 0 为 RGB，分量范围 0~65535，第四个分量不使用
+			binary.Write(&buf, binary.BigEndian, [5]uint16{
+				0,
+				uint16(color[0]) * 257,
+				uint16(color[1]) * 257,
+				uint16(color[2]) * 257,
+				0,
+			})
+			if version == 2 {
+				units := append(utf16.Encode([]rune(strconv.Itoa(i))), 0)
+				binary.Write(&buf, binary.BigEndian, uint32(len(units)))
+				binary.Write(&buf, binary.BigEndian, units)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// paletteJSON JSON 调色板文档
+type paletteJSON struct {
+	Name   string             `json:"name"`
+	Pixels int                `json:"pixels"`
+	Colors []paletteJSONColor `json:"colors"`
+}
+
+// paletteJSONColor JSON 调色板中的一个颜色
+type paletteJSONColor struct {
+	Index      int        `json:"index"`
+	Hex        string     `json:"hex"`
+	RGB        [3]uint8   `json:"rgb"`
+	Alpha      uint8      `json:"alpha"`
+	Lab        [3]float64 `json:"lab"`
+	PixelCount int        `json:"pixelCount"`
+	Coverage   float64    `json:"coverage"` // 像素数占全部像素的比例
+}
+
+// EncodePaletteJSON 编码为包含 hex/RGB/Lab 值及像素覆盖率的 JSON
+func EncodePaletteJSON(colorMap *ColorMap, name string) []byte {
+	counts := make([]int, len(colorMap.Colors))
+	for _, index := range colorMap.MappedIndices.Data {
+		if int(index) < len(counts) {
+			counts[index]++
+		}
+	}
+
+	total := len(colorMap.MappedIndices.Data)
+	doc := paletteJSON{
+		Name:   name,
+		Pixels: total,
+		Colors: make([]paletteJSONColor, len(colorMap.Colors)),
+	}
+	for i, color := range colorMap.Colors {
+		lab := rgbToLab(color[0], color[1], color[2])
+		entry := paletteJSONColor{
+			Index:      i,
+			Hex:        colorToHex(color),
+			RGB:        [3]uint8{color[0], color[1], color[2]},
+			Alpha:      color[3],
+			PixelCount: counts[i],
+		}
+		for c := 0; c < 3; c++ {
+			entry.Lab[c] = math.Round(lab[c]*100) / 100
+		}
+		if total > 0 {
+			entry.Coverage = float64(counts[i]) / float64(total)
+		}
+		doc.Colors[i] = entry
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// EncodeCSS 编码为 CSS 自定义属性，变量名为 --name-索引
+func EncodeCSS(colors [][4]uint8, name string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(":root {\n")
+	for i, color := range colors {
+		if color[3] == 0 {
+			continue
+		}
+		value := colorToHex(color)
+		if color[3] < 255 {
+			value += fmt.Sprintf("%02x", color[3])
+		}
+		fmt.Fprintf(&buf, "  --%s-%d: %s;\n", cssIdentifier(name), i, value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// cssIdentifier 将名称转换为合法的 CSS 变量名片段
+func cssIdentifier(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('-')
+		}
+	}
+	if sb.Len() == 0 {
+		return DEFAULT_PALETTE_NAME
+	}
+	return sb.String()
+}
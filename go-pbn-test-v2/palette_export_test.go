@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"unicode/utf16"
+)
+
+// testPalette 测试用调色板，最后一个透明颜色在导出时应被跳过
+var testPalette = [][4]uint8{{255, 0, 0, 255}, {12, 34, 56, 255}, {0, 255, 128, 200}, {0, 0, 0, 0}}
+
+// opaqueEntries 返回调色板中应被导出的颜色及其索引
+func opaqueEntries(colors [][4]uint8) map[int][3]uint8 {
+	entries := make(map[int][3]uint8)
+	for i, color := range colors {
+		if color[3] != 0 {
+			entries[i] = [3]uint8{color[0], color[1], color[2]}
+		}
+	}
+	return entries
+}
+
+// readUTF16 读取带长度前缀、以 0 结尾的 UTF-16BE 字符串
+func readUTF16(t *testing.T, r *bytes.Reader, length int) string {
+	t.Helper()
+	units := make([]uint16, length)
+	if err := binary.Read(r, binary.BigEndian, units); err != nil {
+		t.Fatal(err)
+	}
+	if length == 0 || units[length-1] != 0 {
+		t.Fatalf("string %v is not zero terminated", units)
+	}
+	return string(utf16.Decode(units[:length-1]))
+}
+
+func TestEncodeGPLRoundTrip(t *testing.T) {
+	data := EncodeGPL(testPalette, "海报")
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	header := []string{"GIMP Palette", "Name: 海报", "Columns: 0", "#"}
+	for _, want := range header {
+		if !scanner.Scan() || scanner.Text() != want {
+			t.Fatalf("header line %q, want %q", scanner.Text(), want)
+		}
+	}
+
+	got := make(map[int][3]uint8)
+	for scanner.Scan() {
+		var r, g, b, index int
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d %d\t%d", &r, &g, &b, &index); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got[index] = [3]uint8{uint8(r), uint8(g), uint8(b)}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(opaqueEntries(testPalette)) {
+		t.Fatalf("colors %v, want %v", got, opaqueEntries(testPalette))
+	}
+}
+
+func TestEncodeASERoundTrip(t *testing.T) {
+	r := bytes.NewReader(EncodeASE(testPalette, "海报"))
+	var header struct {
+		Signature    [4]byte
+		Major, Minor uint16
+		Blocks       uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	if string(header.Signature[:]) != "ASEF" || header.Major != 1 || header.Minor != 0 {
+		t.Fatalf("header %+v", header)
+	}
+
+	got := make(map[int][3]uint8)
+	var types []uint16
+	for i := uint32(0); i < header.Blocks; i++ {
+		var block struct {
+			Type   uint16
+			Length uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &block); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, block.Type)
+		body := make([]byte, block.Length)
+		if _, err := r.Read(body); err != nil && block.Length > 0 {
+			t.Fatal(err)
+		}
+		br := bytes.NewReader(body)
+
+		switch block.Type {
+		case 0xC001:
+			var length uint16
+			binary.Read(br, binary.BigEndian, &length)
+			if name := readUTF16(t, br, int(length)); name != "海报" {
+				t.Fatalf("group name %q", name)
+			}
+		case 0x0001:
+			var length uint16
+			binary.Read(br, binary.BigEndian, &length)
+			index, err := strconv.Atoi(readUTF16(t, br, int(length)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var entry struct {
+				Model     [4]byte
+				RGB       [3]float32
+				ColorType uint16
+			}
+			if err := binary.Read(br, binary.BigEndian, &entry); err != nil {
+				t.Fatal(err)
+			}
+			if string(entry.Model[:]) != "RGB " || entry.ColorType != 2 || br.Len() != 0 {
+				t.Fatalf("color block %+v with %d trailing bytes", entry, br.Len())
+			}
+			var color [3]uint8
+			for c, value := range entry.RGB {
+				color[c] = uint8(math.Round(float64(value) * 255))
+			}
+			got[index] = color
+		}
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d trailing bytes", r.Len())
+	}
+	if types[0] != 0xC001 || types[len(types)-1] != 0xC002 {
+		t.Fatalf("block types %x", types)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(opaqueEntries(testPalette)) {
+		t.Fatalf("colors %v, want %v", got, opaqueEntries(testPalette))
+	}
+}
+
+func TestEncodeACORoundTrip(t *testing.T) {
+	r := bytes.NewReader(EncodeACO(testPalette))
+	want := opaqueEntries(testPalette)
+	// 版本 1 没有名称，颜色按调色板索引顺序排列
+	var order []int
+	for i, color := range testPalette {
+		if color[3] != 0 {
+			order = append(order, i)
+		}
+	}
+	for version := uint16(1); version <= 2; version++ {
+		var header [2]uint16
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			t.Fatal(err)
+		}
+		if header[0] != version || int(header[1]) != len(want) {
+			t.Fatalf("version %d header %v", version, header)
+		}
+
+		var colors [][3]uint8
+		var names []int
+		for i := 0; i < int(header[1]); i++ {
+			var entry [5]uint16
+			if err := binary.Read(r, binary.BigEndian, &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry[0] != 0 {
+				t.Fatalf("color space %d", entry[0])
+			}
+			colors = append(colors, [3]uint8{uint8(entry[1] / 257), uint8(entry[2] / 257), uint8(entry[3] / 257)})
+			if version == 2 {
+				var length uint32
+				binary.Read(r, binary.BigEndian, &length)
+				index, err := strconv.Atoi(readUTF16(t, r, int(length)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, index)
+			}
+		}
+
+		for i, color := range colors {
+			index := order[i]
+			if version == 2 {
+				index = names[i]
+			}
+			if want[index] != color {
+				t.Fatalf("version %d color %d = %v, want %v", version, index, color, want[index])
+			}
+		}
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d trailing bytes", r.Len())
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"sort"
+)
+
+// 调色板排序方式
+const (
+	PALETTE_SORT_NONE      = iota // 保持生成顺序
+	PALETTE_SORT_LUMINANCE        // 按亮度从亮到暗
+	PALETTE_SORT_HUE              // 先按色相分组，组内从亮到暗，灰色排在最前
+	PALETTE_SORT_FREQUENCY        // 按像素数从多到少
+	PALETTE_SORT_NEAREST          // 从最亮的颜色开始，每次接上 Lab 中最接近的颜色
+)
+
+// 按色相排序的参数
+const (
+	PALETTE_SORT_HUE_BUCKETS = 12  // 色相分组数，每组 30 度
+	PALETTE_SORT_GREY_CHROMA = 8.0 // 彩度低于该值的颜色视为灰色
+)
+
+// SortPalette 按指定方式重新排列 ColorMap 的调色板，并同步更新每个像素的颜色索引
+// 完全透明的颜色始终排在最后。返回旧索引到新索引的映射，可用于更新其他引用颜色索引的数据
+func SortPalette(colorMap *ColorMap, mode int) []uint8 {
+	order := make([]int, len(colorMap.Colors))
+	for i := range order {
+		order[i] = i
+	}
+
+	labs := make([][3]float64, len(colorMap.Colors))
+	for i, color := range colorMap.Colors {
+		labs[i] = rgbToLab(color[0], color[1], color[2])
+	}
+
+	switch mode {
+	case PALETTE_SORT_LUMINANCE:
+		sort.SliceStable(order, func(i, j int) bool {
+			return labs[order[i]][0] > labs[order[j]][0]
+		})
+	case PALETTE_SORT_HUE:
+		groups := make([]int, len(labs))
+		for i, lab := range labs {
+			groups[i] = hueGroup(lab)
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := order[i], order[j]
+			if groups[a] != groups[b] {
+				return groups[a] < groups[b]
+			}
+			return labs[a][0] > labs[b][0]
+		})
+	case PALETTE_SORT_FREQUENCY:
+		counts := make([]int, len(colorMap.Colors))
+		for _, index := range colorMap.MappedIndices.Data {
+			if int(index) < len(counts) {
+				counts[index]++
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return counts[order[i]] > counts[order[j]]
+		})
+	case PALETTE_SORT_NEAREST:
+		order = nearestNeighbourChain(labs)
+	}
+
+	// 透明颜色移到末尾
+	sort.SliceStable(order, func(i, j int) bool {
+		return colorMap.Colors[order[i]][3] != 0 && colorMap.Colors[order[j]][3] == 0
+	})
+
+	colors := make([][4]uint8, len(order))
+	remap := make([]uint8, len(order))
+	for newIndex, oldIndex := range order {
+		colors[newIndex] = colorMap.Colors[oldIndex]
+		remap[oldIndex] = uint8(newIndex)
+	}
+	colorMap.RemapColors(colors, remap)
+	return remap
+}
+
+// RemapColors 替换调色板，并按 remap（旧索引到新索引）更新每个像素的颜色索引
+func (cm *ColorMap) RemapColors(colors [][4]uint8, remap []uint8) {
+	for i, index := range cm.MappedIndices.Data {
+		if int(index) < len(remap) {
+			cm.MappedIndices.Data[i] = remap[index]
+		}
+	}
+	cm.Colors = colors
+}
+
+// hueGroup 返回颜色的色相分组，灰色为 0，其余为 1~PALETTE_SORT_HUE_BUCKETS
+func hueGroup(lab [3]float64) int {
+	a, b := lab[1], lab[2]
+	if a*a+b*b < PALETTE_SORT_GREY_CHROMA*PALETTE_SORT_GREY_CHROMA {
+		return 0
+	}
+	bucket := int(hueAngle(a, b) / (360.0 / PALETTE_SORT_HUE_BUCKETS))
+	if bucket >= PALETTE_SORT_HUE_BUCKETS {
+		bucket = PALETTE_SORT_HUE_BUCKETS - 1
+	}
+	return bucket + 1
+}
+
+// nearestNeighbourChain 从最亮的颜色开始，每次选择与上一个颜色在 Lab 中最接近的未使用颜色
+func nearestNeighbourChain(labs [][3]float64) []int {
+	order := make([]int, 0, len(labs))
+	if len(labs) == 0 {
+		return order
+	}
+
+	used := make([]bool, len(labs))
+	current := 0
+	for i, lab := range labs {
+		if lab[0] > labs[current][0] {
+			current = i
+		}
+	}
+	for {
+		order = append(order, current)
+		used[current] = true
+
+		next := -1
+		minDistance := 0.0
+		for i, lab := range labs {
+			if used[i] {
+				continue
+			}
+			d0 := lab[0] - labs[current][0]
+			d1 := lab[1] - labs[current][1]
+			d2 := lab[2] - labs[current][2]
+			if distance := d0*d0 + d1*d1 + d2*d2; next < 0 || distance < minDistance {
+				next = i
+				minDistance = distance
+			}
+		}
+		if next < 0 {
+			return order
+		}
+		current = next
+	}
+}
+
+// ParsePaletteSort 根据名称返回调色板排序方式，未知名称返回 PALETTE_SORT_NONE
+func ParsePaletteSort(name string) int {
+	switch name {
+	case "luminance", "lightness":
+		return PALETTE_SORT_LUMINANCE
+	case "hue":
+		return PALETTE_SORT_HUE
+	case "frequency", "count":
+		return PALETTE_SORT_FREQUENCY
+	case "nearest", "chain":
+		return PALETTE_SORT_NEAREST
+	default:
+		return PALETTE_SORT_NONE
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestSortPaletteKeepsColors(t *testing.T) {
+	modes := []int{PALETTE_SORT_NONE, PALETTE_SORT_LUMINANCE, PALETTE_SORT_HUE, PALETTE_SORT_FREQUENCY, PALETTE_SORT_NEAREST}
+	for _, mode := range modes {
+		options := DefaultQuantizerOptions()
+		options.Alpha.Threshold = 128
+		// 锁定的透明颜色不在末尾，排序后应移到末尾
+		options.LockedColors = [][4]uint8{{0, 0, 0, 0}}
+		bmp := noisyBitmap(32, 16, 1)
+		bmp.Data[3] = 0
+		quantizer := NewQuantizerWithOptions(bmp, 12, options)
+		quantizer.BuildPalette()
+		colorMap := quantizer.MapPixels()
+		facetResult := BuildFacets(colorMap)
+
+		before := colorMap.ToImage().Data
+		facetColors := make([][4]uint8, len(facetResult.Facets))
+		for i, facet := range facetResult.Facets {
+			facetColors[i] = colorMap.Colors[facet.ColorIndex]
+		}
+		transparent := colorMap.Colors[quantizer.TransparentIndex]
+
+		remap := SortPalette(colorMap, mode)
+		facetResult.RemapColors(remap)
+
+		checkPermutation(t, remapInts(remap), len(colorMap.Colors))
+		if after := colorMap.ToImage().Data; string(after) != string(before) {
+			t.Fatalf("mode %d: pixel colors changed after sorting", mode)
+		}
+		for i, facet := range facetResult.Facets {
+			if colorMap.Colors[facet.ColorIndex] != facetColors[i] {
+				t.Fatalf("mode %d: facet %d color %v, want %v", mode, i, colorMap.Colors[facet.ColorIndex], facetColors[i])
+			}
+		}
+		if index := int(remap[quantizer.TransparentIndex]); colorMap.Colors[index] != transparent || transparent[3] != 0 {
+			t.Fatalf("mode %d: transparent index %d points to %v", mode, index, colorMap.Colors[index])
+		}
+
+		// 透明颜色都排在最后
+		for i := 1; i < len(colorMap.Colors); i++ {
+			if colorMap.Colors[i-1][3] == 0 && colorMap.Colors[i][3] != 0 {
+				t.Fatalf("mode %d: transparent color before opaque color in %v", mode, colorMap.Colors)
+			}
+		}
+		checkSortOrder(t, colorMap, mode)
+	}
+}
+
+// checkSortOrder 检查不透明颜色按排序方式排列
+func checkSortOrder(t *testing.T, colorMap *ColorMap, mode int) {
+	t.Helper()
+	counts := make([]int, len(colorMap.Colors))
+	for _, index := range colorMap.MappedIndices.Data {
+		counts[index]++
+	}
+	for i := 1; i < len(colorMap.Colors); i++ {
+		a, b := colorMap.Colors[i-1], colorMap.Colors[i]
+		if b[3] == 0 {
+			break
+		}
+		labA, labB := rgbToLab(a[0], a[1], a[2]), rgbToLab(b[0], b[1], b[2])
+		switch mode {
+		case PALETTE_SORT_LUMINANCE:
+			if labA[0] < labB[0] {
+				t.Fatalf("colors %d and %d are not sorted by lightness", i-1, i)
+			}
+		case PALETTE_SORT_HUE:
+			if groupA, groupB := hueGroup(labA), hueGroup(labB); groupA > groupB || (groupA == groupB && labA[0] < labB[0]) {
+				t.Fatalf("colors %d and %d are not sorted by hue", i-1, i)
+			}
+		case PALETTE_SORT_FREQUENCY:
+			if counts[i-1] < counts[i] {
+				t.Fatalf("colors %d and %d are not sorted by pixel count", i-1, i)
+			}
+		}
+	}
+}
+
+// remapInts 将索引映射转换为整数切片
+func remapInts(remap []uint8) []int {
+	ints := make([]int, len(remap))
+	for i, index := range remap {
+		ints[i] = int(index)
+	}
+	return ints
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+// lockedTestColors 测试用锁定颜色，其中一种与渐变中的颜色都不接近
+var lockedTestColors = [][4]uint8{{255, 255, 0, 255}, {10, 10, 10, 255}, {128, 128, 128, 255}}
+
+func TestLockedColorsKeptAtStart(t *testing.T) {
+	algorithms := []int{QUANTIZER_WU, QUANTIZER_MEDIAN_CUT, QUANTIZER_OCTREE, QUANTIZER_NEUQUANT}
+	for _, algorithm := range algorithms {
+		for _, threshold := range []uint8{0, 128} {
+			options := DefaultQuantizerOptions()
+			options.LockedColors = lockedTestColors
+			options.Alpha.Threshold = threshold
+			options.Refine.Iterations = 3
+			quantizer := NewColorQuantizer(algorithm, gradientBitmap(32, 16), 8, options)
+			palette := quantizer.BuildPalette()
+
+			if len(palette) > 8 {
+				t.Fatalf("algorithm %d, threshold %d: palette has %d colors", algorithm, threshold, len(palette))
+			}
+			for i, color := range lockedTestColors {
+				if palette[i] != color {
+					t.Fatalf("algorithm %d, threshold %d: palette[%d] = %v, want locked %v", algorithm, threshold, i, palette[i], color)
+				}
+			}
+			// 透明颜色占用最后一个位置，锁定颜色不会挤掉它
+			if hasTransparent := palette[len(palette)-1] == [4]uint8{}; hasTransparent != (threshold > 0) {
+				t.Fatalf("algorithm %d, threshold %d: last color %v", algorithm, threshold, palette[len(palette)-1])
+			}
+
+			// 映射结果的调色板中锁定颜色仍位于开头
+			colorMap := quantizer.MapPixels()
+			if !samePalette(colorMap.Colors[:len(lockedTestColors)], lockedTestColors) {
+				t.Fatalf("algorithm %d: mapped palette %v", algorithm, colorMap.Colors)
+			}
+		}
+	}
+}
+
+func TestLockedColorsWithTransparentSlot(t *testing.T) {
+	// 锁定颜色数等于颜色数时仍然为透明颜色追加一个位置
+	options := DefaultQuantizerOptions()
+	options.LockedColors = lockedTestColors
+	options.Alpha.Threshold = 128
+	palette := NewQuantizerWithOptions(gradientBitmap(32, 16), len(lockedTestColors), options).BuildPalette()
+	want := append(append([][4]uint8{}, lockedTestColors...), [4]uint8{})
+	if !samePalette(palette, want) {
+		t.Fatalf("palette %v, want %v", palette, want)
+	}
+
+	// 锁定颜色超过上限时截断，为透明颜色保留一个位置
+	many := make([][4]uint8, MAX_COLOR+10)
+	for i := range many {
+		many[i] = [4]uint8{uint8(i), uint8(i / 2), 0, 255}
+	}
+	options.LockedColors = many
+	palette = NewQuantizerWithOptions(gradientBitmap(32, 16), MAX_COLOR, options).BuildPalette()
+	if len(palette) != MAX_COLOR || palette[MAX_COLOR-1] != [4]uint8{} || !samePalette(palette[:MAX_COLOR-1], many[:MAX_COLOR-1]) {
+		t.Fatalf("palette has %d colors, last %v", len(palette), palette[len(palette)-1])
+	}
+	options.Alpha.Threshold = 0
+	if got := limitLockedColors(many, options.Alpha); !samePalette(got, many[:MAX_COLOR]) {
+		t.Fatalf("without transparency %d locked colors are kept", len(got))
+	}
+}
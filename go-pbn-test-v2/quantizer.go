@@ -9,15 +9,63 @@ const (
 	RED              = iota // 红色通道
 	GREEN                   // 绿色通道
 	BLUE                    // 蓝色通道
+	ALPHA                   // Alpha 通道，仅在量化 Alpha 时作为直方图的第四个维度
 	MAX_COLOR        = 256
-	SIGNIFICANT_BITS = 5
-	MAX_SIDE_INDEX   = 1 << SIGNIFICANT_BITS
-	SIDE_SIZE        = MAX_SIDE_INDEX + 1
+	DEFAULT_COLORS   = 16 // 默认量化颜色数
+	SIGNIFICANT_BITS = 5  // 默认的直方图有效位数
 )
 
-// getIndex 根据红绿蓝值计算索引
-func getIndex(r, g, b int) int {
-	return r*SIDE_SIZE*SIDE_SIZE + g*SIDE_SIZE + b
+// 直方图精度限制
+const (
+	MIN_SIGNIFICANT_BITS        = 4
+	MAX_SIGNIFICANT_BITS        = 7
+	ALPHA_SIGNIFICANT_BITS      = 3        // 量化 Alpha 时 Alpha 维度的有效位数，较低的精度即可区分半透明和不透明
+	DEFAULT_MAX_HISTOGRAM_BYTES = 32 << 20 // 直方图矩数组占用内存的默认上限，WASM 线性内存有限
+)
+
+// getIndex 根据红绿蓝及 Alpha 的直方图坐标计算索引，不量化 Alpha 时 a 始终为 0
+func (q *Quantizer) getIndex(r, g, b, a int) int {
+	return ((r*q.sideSize+g)*q.sideSize+b)*q.alphaSide + a
+}
+
+// alphaSideSize 返回直方图 Alpha 维度的大小，不量化 Alpha 时为 1
+func alphaSideSize(quantizeAlpha bool) int {
+	if quantizeAlpha {
+		return 1<<ALPHA_SIGNIFICANT_BITS + 1
+	}
+	return 1
+}
+
+// histogramBits 将直方图有效位数限制在 MIN_SIGNIFICANT_BITS~MAX_SIGNIFICANT_BITS，
+// 并在矩数组超过 maxBytes（0 表示 DEFAULT_MAX_HISTOGRAM_BYTES）时降低精度
+func histogramBits(bits int, quantizeAlpha bool, maxBytes int) int {
+	if bits == 0 {
+		bits = SIGNIFICANT_BITS
+	}
+	if bits < MIN_SIGNIFICANT_BITS {
+		bits = MIN_SIGNIFICANT_BITS
+	}
+	if bits > MAX_SIGNIFICANT_BITS {
+		bits = MAX_SIGNIFICANT_BITS
+	}
+
+	if maxBytes <= 0 {
+		maxBytes = DEFAULT_MAX_HISTOGRAM_BYTES
+	}
+	for bits > MIN_SIGNIFICANT_BITS && histogramBytes(bits, quantizeAlpha) > maxBytes {
+		bits--
+	}
+	return bits
+}
+
+// histogramBytes 计算指定精度下矩数组占用的字节数
+func histogramBytes(bits int, quantizeAlpha bool) int {
+	arrays := 5 // Weights、MomentsRed、MomentsGreen、MomentsBlue、Moments
+	if quantizeAlpha {
+		arrays++
+	}
+	side := 1<<bits + 1
+	return side * side * side * alphaSideSize(quantizeAlpha) * arrays * 8
 }
 
 // ColorCube 代表一个颜色立方体
@@ -25,6 +73,7 @@ type ColorCube struct {
 	RedMin, RedMax     int
 	GreenMin, GreenMax int
 	BlueMin, BlueMax   int
+	AlphaMin, AlphaMax int // 仅在量化 Alpha 时使用，否则均为 0
 	Volume             int
 }
 
@@ -211,15 +260,88 @@ type Quantizer struct {
 	Cubes        []*ColorCube
 	Palette      [][4]uint8
 	Bitmap       *Bitmap
+	ColorSpace   int           // 直方图统计、立方体分割和最近颜色查找所使用的颜色空间
+	Dither       DitherOptions // 映射像素时使用的抖动选项
+	Alpha        AlphaOptions  // 透明度处理策略
+	MomentsAlpha []float64     // Alpha 的一阶矩，仅在 Alpha.QuantizeAlpha 时使用
+	LockedColors [][4]uint8    // 锁定颜色，按顺序位于调色板开头
+	Refine       RefineOptions // Wu 算法之后的 K-means 优化选项
+
+	SignificantBits int // 直方图每个分量的有效位数
+	sideSize        int // 直方图红绿蓝维度的大小，为 2^SignificantBits + 1
+	alphaSide       int // 直方图 Alpha 维度的大小，量化 Alpha 时为 2^ALPHA_SIGNIFICANT_BITS + 1，否则为 1
+
+	TransparentIndex  int  // 透明颜色在调色板中的索引，-1 表示没有
+	transparentPixels int  // 采样时被视为透明的像素数
+	fixedPalette      bool // 是否使用调用方提供的固定调色板
+
+	lockedRadius  float64      // 与锁定颜色距离不超过该值的像素不参与直方图统计
+	lockedWorking [][3]float64 // 锁定颜色在工作颜色空间中的坐标
+
+	errorCurve      []float64      // 不为 nil 时 preparePalette 记录每次分割后的总误差
+	adaptiveWeights []float64      // 算法选择的每种颜色所代表的像素数，如 Wu 立方体的权重
+	paletteWeights  []float64      // 与 Palette 对齐的权重，锁定颜色和透明颜色为 0
+	paletteWorking  [][4]float64   // 调色板在工作颜色空间中的坐标及 Alpha，用于最近颜色查找
+	lookup          *paletteLookup // 加速的最近颜色查找结构
+}
+
+// QuantizerOptions 量化器选项
+type QuantizerOptions struct {
+	ColorSpace int           // 颜色空间，COLOR_SPACE_RGB / COLOR_SPACE_LAB / COLOR_SPACE_OKLAB
+	Dither     DitherOptions // 抖动选项
+	Alpha      AlphaOptions  // 透明度处理策略
+
+	// 锁定颜色，必定出现在调色板开头，其余颜色由 Wu 算法围绕它们选择
+	LockedColors [][4]uint8
+	// 工作颜色空间中与锁定颜色距离不超过该值的像素视为已由锁定颜色表示，不参与直方图统计
+	LockedRadius float64
+
+	Refine RefineOptions // K-means 优化选项
+
+	// 直方图有效位数（4~7），0 表示使用 SIGNIFICANT_BITS
+	// 精度越高，相近的颜色越不容易落入同一个立方体，但内存和计算量按 8 倍增长
+	// 超过 MaxHistogramBytes 时自动降低，实际使用的位数见 Quantizer.SignificantBits
+	HistogramBits int
+	// 直方图矩数组占用内存的上限（字节），0 表示 DEFAULT_MAX_HISTOGRAM_BYTES
+	MaxHistogramBytes int
+}
+
+// AlphaOptions 透明度处理策略
+type AlphaOptions struct {
+	// Alpha 小于该值的像素视为透明：不参与直方图统计，并映射到调色板末尾专用的透明颜色
+	// 0 表示不处理透明
+	Threshold uint8
+	// 将 Alpha 作为直方图的第四个维度（ALPHA_SIGNIFICANT_BITS 位精度），立方体可以沿 Alpha 分割，
+	// 调色板颜色的 Alpha 取立方体内的平均值。直方图内存随之增加 2^ALPHA_SIGNIFICANT_BITS + 1 倍
+	QuantizeAlpha bool
+}
+
+// DefaultQuantizerOptions 返回默认的量化器选项
+func DefaultQuantizerOptions() QuantizerOptions {
+	return QuantizerOptions{
+		ColorSpace:   COLOR_SPACE_RGB,
+		Dither:       DefaultDitherOptions(),
+		LockedRadius: DEFAULT_LOCKED_RADIUS,
+		Refine:       DefaultRefineOptions(),
+
+		HistogramBits: SIGNIFICANT_BITS,
+	}
 }
 
 // NewQuantizer 创建一个新的 Quantizer 实例
 func NewQuantizer(bitmap *Bitmap, colors int) *Quantizer {
-	if colors > MAX_COLOR {
-		colors = MAX_COLOR
-	}
+	return NewQuantizerWithOptions(bitmap, colors, DefaultQuantizerOptions())
+}
 
-	totalSize := SIDE_SIZE * SIDE_SIZE * SIDE_SIZE
+// NewQuantizerWithOptions 根据选项创建一个新的 Quantizer 实例
+func NewQuantizerWithOptions(bitmap *Bitmap, colors int, options QuantizerOptions) *Quantizer {
+	locked := limitLockedColors(options.LockedColors, options.Alpha)
+	colors = normalizeColorCount(colors, locked)
+
+	bits := histogramBits(options.HistogramBits, options.Alpha.QuantizeAlpha, options.MaxHistogramBytes)
+	sideSize := 1<<bits + 1
+	alphaSide := alphaSideSize(options.Alpha.QuantizeAlpha)
+	totalSize := sideSize * sideSize * sideSize * alphaSide
 
 	weights := make([]float64, totalSize)
 	momentsRed := make([]float64, totalSize)
@@ -245,17 +367,6 @@ func NewQuantizer(bitmap *Bitmap, colors int) *Quantizer {
 		palette[i] = [4]uint8{0, 0, 0, 0}
 	}
 
-	// 正确初始化cubes[0]
-	cubes[0].RedMin = 0
-	cubes[0].RedMax = SIDE_SIZE - 1
-	cubes[0].GreenMin = 0
-	cubes[0].GreenMax = SIDE_SIZE - 1
-	cubes[0].BlueMin = 0
-	cubes[0].BlueMax = SIDE_SIZE - 1
-	cubes[0].Volume = (cubes[0].RedMax - cubes[0].RedMin) *
-		(cubes[0].GreenMax - cubes[0].GreenMin) *
-		(cubes[0].BlueMax - cubes[0].BlueMin)
-
 	quant := &Quantizer{
 		Colors:       colors,
 		Weights:      weights,
@@ -267,7 +378,25 @@ func NewQuantizer(bitmap *Bitmap, colors int) *Quantizer {
 		Cubes:        cubes,
 		Palette:      palette,
 		Bitmap:       bitmap.Clone(),
+		ColorSpace:   options.ColorSpace,
+		Dither:       options.Dither,
+		Alpha:        options.Alpha,
+
+		LockedColors: locked,
+		Refine:       options.Refine,
+
+		SignificantBits: bits,
+		sideSize:        sideSize,
+		alphaSide:       alphaSide,
+
+		TransparentIndex: -1,
+		lockedRadius:     options.LockedRadius,
 	}
+	if options.Alpha.QuantizeAlpha {
+		quant.MomentsAlpha = make([]float64, totalSize)
+	}
+	quant.lockedWorking = lockedWorkingColors(options.ColorSpace, locked)
+	quant.resetCubes()
 
 	quant.sample(bitmap.Data)
 	return quant
@@ -279,25 +408,54 @@ func (q *Quantizer) sample(pixels []uint8) {
 		if i+2 >= len(pixels) {
 			break
 		}
-		r, g, b := pixels[i], pixels[i+1], pixels[i+2]
-		q.addColor([3]uint8{r, g, b})
+		r, g, b, a := pixels[i], pixels[i+1], pixels[i+2], uint8(255)
+		if i+3 < len(pixels) {
+			a = pixels[i+3]
+		}
+		if a < q.Alpha.Threshold {
+			q.transparentPixels++
+			continue
+		}
+		if len(q.lockedWorking) > 0 && q.coveredByLockedColor(toWorkingSpace(q.ColorSpace, r, g, b)) {
+			continue
+		}
+
+		var index int
+		if q.ColorSpace == COLOR_SPACE_RGB {
+			index = q.addColor([3]uint8{r, g, b}, [3]float64{float64(r), float64(g), float64(b)}, a)
+		} else {
+			// 感知颜色空间中的坐标已缩放到 0~255，取整后作为直方图坐标，矩中累加未取整的坐标
+			c := toWorkingSpace(q.ColorSpace, r, g, b)
+			index = q.addColor([3]uint8{clampToUint8(c[0]), clampToUint8(c[1]), clampToUint8(c[2])}, c, a)
+		}
+		if q.MomentsAlpha != nil {
+			q.MomentsAlpha[index] += float64(a)
+			q.Moments[index] += q.Table[a]
+		}
 	}
 }
 
-// addColor 将单个RGB颜色添加到权重和矩中
-func (q *Quantizer) addColor(color [3]uint8) {
-	bitsToRemove := 8 - SIGNIFICANT_BITS
-	indexRed := int(color[0]>>bitsToRemove) + 1
-	indexGreen := int(color[1]>>bitsToRemove) + 1
-	indexBlue := int(color[2]>>bitsToRemove) + 1
+// addColor 将单个颜色添加到权重和矩中，返回直方图索引
+// cell 为取整后的坐标，用于确定直方图单元；color 为工作颜色空间中的实际坐标，累加到矩中
+// alpha 仅在量化 Alpha 时用于确定 Alpha 维度的单元
+func (q *Quantizer) addColor(cell [3]uint8, color [3]float64, alpha uint8) int {
+	bitsToRemove := 8 - q.SignificantBits
+	indexRed := int(cell[0]>>bitsToRemove) + 1
+	indexGreen := int(cell[1]>>bitsToRemove) + 1
+	indexBlue := int(cell[2]>>bitsToRemove) + 1
+	indexAlpha := 0
+	if q.alphaSide > 1 {
+		indexAlpha = int(alpha>>(8-ALPHA_SIGNIFICANT_BITS)) + 1
+	}
 
-	index := getIndex(indexRed, indexGreen, indexBlue)
+	index := q.getIndex(indexRed, indexGreen, indexBlue, indexAlpha)
 
 	q.Weights[index] += 1.0
-	q.MomentsRed[index] += float64(color[0])
-	q.MomentsGreen[index] += float64(color[1])
-	q.MomentsBlue[index] += float64(color[2])
-	q.Moments[index] += q.Table[color[0]] + q.Table[color[1]] + q.Table[color[2]]
+	q.MomentsRed[index] += color[0]
+	q.MomentsGreen[index] += color[1]
+	q.MomentsBlue[index] += color[2]
+	q.Moments[index] += color[0]*color[0] + color[1]*color[1] + color[2]*color[2]
+	return index
 }
 
 // Quantize 执行量化并返回量化后的 Bitmap
@@ -308,50 +466,148 @@ func (q *Quantizer) Quantize() *Bitmap {
 
 // BuildPalette 执行量化过程，返回调色板（RGBA格式，Alpha固定为255）
 func (q *Quantizer) BuildPalette() [][4]uint8 {
+	if q.fixedPalette {
+		return q.Palette
+	}
+
 	q.calculateMoments()
-	palette := q.preparePalette()
+
+	return q.assemblePalette(q.buildAdaptivePalette)
+}
+
+// buildAdaptivePalette 使用 Wu 算法选择 colors 种颜色，需要时再执行 K-means 优化
+func (q *Quantizer) buildAdaptivePalette(colors int) [][4]uint8 {
+	q.Colors = colors
+	adaptive := q.preparePalette()
+	if q.Refine.Iterations > 0 {
+		adaptive = q.refinePalette()
+	}
+	return adaptive
+}
+
+// resetCubes 将 cubes[0] 恢复为整个直方图，其余立方体清空
+func (q *Quantizer) resetCubes() {
+	for i := range q.Cubes {
+		q.Cubes[i] = NewColorCube()
+	}
+
+	// 正确初始化cubes[0]
+	cube := q.Cubes[0]
+	cube.RedMax = q.sideSize - 1
+	cube.GreenMax = q.sideSize - 1
+	cube.BlueMax = q.sideSize - 1
+	cube.AlphaMax = q.alphaSide - 1
+	cube.Volume = q.cubeVolume(cube)
+}
+
+// cubeVolume 计算立方体包含的直方图单元数，不量化 Alpha 时不计 Alpha 维度
+func (q *Quantizer) cubeVolume(cube *ColorCube) int {
+	volume := (cube.RedMax - cube.RedMin) *
+		(cube.GreenMax - cube.GreenMin) *
+		(cube.BlueMax - cube.BlueMin)
+	if q.alphaSide > 1 {
+		volume *= cube.AlphaMax - cube.AlphaMin
+	}
+	return volume
+}
+
+// assemblePalette 组合最终的调色板：锁定颜色在前，随后是 adaptive 生成的颜色，透明颜色在末尾
+// adaptive 接收剩余可用的颜色数，返回算法选择的颜色
+func (q *Quantizer) assemblePalette(adaptive func(colors int) [][4]uint8) [][4]uint8 {
+	colors := q.Colors
+
+	// 存在透明像素时为透明颜色保留一个调色板位置
+	reserveTransparent := q.Alpha.Threshold > 0 && q.transparentPixels > 0
+	if reserveTransparent && colors > 1 {
+		colors--
+	}
+
+	// 锁定颜色占用调色板开头的位置，算法只选择剩余的颜色
+	colors -= len(q.LockedColors)
+	palette := append([][4]uint8{}, q.LockedColors...)
+	weights := make([]float64, len(palette))
+	if colors > 0 {
+		q.adaptiveWeights = nil
+		selected := adaptive(colors)
+		palette = append(palette, selected...)
+		if len(q.adaptiveWeights) == len(selected) {
+			weights = append(weights, q.adaptiveWeights...)
+		}
+	}
+
+	q.TransparentIndex = -1
+	if reserveTransparent {
+		q.TransparentIndex = len(palette)
+		palette = append(palette, [4]uint8{0, 0, 0, 0})
+		weights = append(weights, 0)
+	}
+
+	// 算法没有提供权重时不记录，合并颜色时改用像素数
+	q.paletteWeights = nil
+	if len(weights) == len(palette) {
+		q.paletteWeights = weights
+	}
+
 	q.Palette = palette
 	return palette
 }
 
 // calculateMoments 计算积分矩
+// 先在每个 Alpha 层内计算红绿蓝三维的积分，量化 Alpha 时再沿 Alpha 维度累加
 func (q *Quantizer) calculateMoments() {
-	for r := 1; r < SIDE_SIZE; r++ {
-		for g := 1; g < SIDE_SIZE; g++ {
-			for b := 1; b < SIDE_SIZE; b++ {
-				index := getIndex(r, g, b)
-				indexR1 := getIndex(r-1, g, b)
-				indexG1 := getIndex(r, g-1, b)
-				indexB1 := getIndex(r, g, b-1)
-				indexRG1 := getIndex(r-1, g-1, b)
-				indexRB1 := getIndex(r-1, g, b-1)
-				indexGB1 := getIndex(r, g-1, b-1)
-				indexRGB1 := getIndex(r-1, g-1, b-1)
-
-				q.Weights[index] += q.Weights[indexR1] + q.Weights[indexG1] + q.Weights[indexB1] -
-					q.Weights[indexRG1] - q.Weights[indexRB1] - q.Weights[indexGB1] + q.Weights[indexRGB1]
-
-				q.MomentsRed[index] += q.MomentsRed[indexR1] + q.MomentsRed[indexG1] + q.MomentsRed[indexB1] -
-					q.MomentsRed[indexRG1] - q.MomentsRed[indexRB1] - q.MomentsRed[indexGB1] + q.MomentsRed[indexRGB1]
-
-				q.MomentsGreen[index] += q.MomentsGreen[indexR1] + q.MomentsGreen[indexG1] + q.MomentsGreen[indexB1] -
-					q.MomentsGreen[indexRG1] - q.MomentsGreen[indexRB1] - q.MomentsGreen[indexGB1] + q.MomentsGreen[indexRGB1]
-
-				q.MomentsBlue[index] += q.MomentsBlue[indexR1] + q.MomentsBlue[indexG1] + q.MomentsBlue[indexB1] -
-					q.MomentsBlue[indexRG1] - q.MomentsBlue[indexRB1] - q.MomentsBlue[indexGB1] + q.MomentsBlue[indexRGB1]
-
-				q.Moments[index] += q.Moments[indexR1] + q.Moments[indexG1] + q.Moments[indexB1] -
-					q.Moments[indexRG1] - q.Moments[indexRB1] - q.Moments[indexGB1] + q.Moments[indexRGB1]
+	moments := [][]float64{q.Weights, q.MomentsRed, q.MomentsGreen, q.MomentsBlue, q.Moments}
+	if q.MomentsAlpha != nil {
+		moments = append(moments, q.MomentsAlpha)
+	}
+
+	// 量化 Alpha 时第 0 层为空，作为积分的边界
+	firstAlpha := 0
+	if q.alphaSide > 1 {
+		firstAlpha = 1
+	}
+
+	for _, moment := range moments {
+		for a := firstAlpha; a < q.alphaSide; a++ {
+			for r := 1; r < q.sideSize; r++ {
+				for g := 1; g < q.sideSize; g++ {
+					for b := 1; b < q.sideSize; b++ {
+						moment[q.getIndex(r, g, b, a)] += moment[q.getIndex(r-1, g, b, a)] +
+							moment[q.getIndex(r, g-1, b, a)] +
+							moment[q.getIndex(r, g, b-1, a)] -
+							moment[q.getIndex(r-1, g-1, b, a)] -
+							moment[q.getIndex(r-1, g, b-1, a)] -
+							moment[q.getIndex(r, g-1, b-1, a)] +
+							moment[q.getIndex(r-1, g-1, b-1, a)]
+					}
+				}
+			}
+		}
+
+		for a := 2; a < q.alphaSide; a++ {
+			for r := 1; r < q.sideSize; r++ {
+				for g := 1; g < q.sideSize; g++ {
+					for b := 1; b < q.sideSize; b++ {
+						moment[q.getIndex(r, g, b, a)] += moment[q.getIndex(r, g, b, a-1)]
+					}
+				}
 			}
 		}
 	}
 }
 
-// preparePalette 准备调色板，返回RGBA格式的颜色数组，Alpha固定为255
+// preparePalette 准备调色板，返回RGBA格式的颜色数组，未量化 Alpha 时Alpha固定为255
 func (q *Quantizer) preparePalette() [][4]uint8 {
 	next := 0
 	volumeVariance := make([]float64, q.Colors+1) // +1 to prevent index out of range
 
+	// 需要记录误差曲线时，跟踪每个立方体的误差
+	var cubeErrors []float64
+	if q.errorCurve != nil {
+		cubeErrors = make([]float64, q.Colors+1)
+		cubeErrors[0] = q.cubeError(q.Cubes[0])
+		q.errorCurve = append(q.errorCurve[:0], cubeErrors[0])
+	}
+
 	for i := 1; i < q.Colors; i++ {
 		if next >= len(q.Cubes) {
 			break
@@ -361,6 +617,10 @@ func (q *Quantizer) preparePalette() [][4]uint8 {
 
 		if !q.cut(cubeNext, cubeI) {
 			volumeVariance[next] = 0.0
+			// 分割失败时误差不变，仍然记录一项，使 errorCurve[i] 始终对应 i+1 种颜色
+			if cubeErrors != nil {
+				q.errorCurve = append(q.errorCurve, q.errorCurve[len(q.errorCurve)-1])
+			}
 			continue
 		}
 
@@ -376,6 +636,16 @@ func (q *Quantizer) preparePalette() [][4]uint8 {
 			volumeVariance[i] = 0.0
 		}
 
+		if cubeErrors != nil {
+			cubeErrors[next] = q.cubeError(cubeNext)
+			cubeErrors[i] = q.cubeError(cubeI)
+			total := 0.0
+			for k := 0; k <= i; k++ {
+				total += cubeErrors[k]
+			}
+			q.errorCurve = append(q.errorCurve, total)
+		}
+
 		// 选择具有最大方差的立方体进行下一步分割
 		next = 0
 		temp := volumeVariance[0]
@@ -397,42 +667,67 @@ func (q *Quantizer) preparePalette() [][4]uint8 {
 
 	// 生成调色板
 	palette := make([][4]uint8, 0, q.Colors)
+	q.adaptiveWeights = make([]float64, 0, q.Colors)
 
 	for k := 0; k < q.Colors; k++ {
 		weight := q.volume(q.Cubes[k], q.Weights)
 		if weight > 0.0 {
+			q.adaptiveWeights = append(q.adaptiveWeights, weight)
 			r := q.volume(q.Cubes[k], q.MomentsRed) / weight
 			g := q.volume(q.Cubes[k], q.MomentsGreen) / weight
 			b := q.volume(q.Cubes[k], q.MomentsBlue) / weight
 
-			// Clamp values between 0 and 255
-			r = math.Min(math.Max(r, 0.0), 255.0)
-			g = math.Min(math.Max(g, 0.0), 255.0)
-			b = math.Min(math.Max(b, 0.0), 255.0)
-
-			palette = append(palette, [4]uint8{
-				uint8(r),
-				uint8(g),
-				uint8(b),
-				255, // Alpha固定为255
-			})
+			alpha := uint8(255)
+			if q.MomentsAlpha != nil {
+				alpha = clampToUint8(q.volume(q.Cubes[k], q.MomentsAlpha) / weight)
+			}
+
+			palette = append(palette, q.paletteColor(r, g, b, alpha))
 		}
 	}
 
 	return palette
 }
 
+// paletteColor 将工作颜色空间中的平均颜色转换为调色板颜色
+func (q *Quantizer) paletteColor(r, g, b float64, alpha uint8) [4]uint8 {
+	if q.ColorSpace != COLOR_SPACE_RGB {
+		rgb := fromWorkingSpace(q.ColorSpace, [3]float64{r, g, b})
+		return [4]uint8{rgb[0], rgb[1], rgb[2], alpha}
+	}
+
+	// Clamp values between 0 and 255
+	r = math.Min(math.Max(r, 0.0), 255.0)
+	g = math.Min(math.Max(g, 0.0), 255.0)
+	b = math.Min(math.Max(b, 0.0), 255.0)
+
+	return [4]uint8{
+		uint8(r),
+		uint8(g),
+		uint8(b),
+		alpha, // 未量化 Alpha 时固定为255
+	}
+}
+
 // cut 分割颜色立方体，更新 first 和 second
 func (q *Quantizer) cut(first, second *ColorCube) bool {
 	wholeRed := q.volume(first, q.MomentsRed)
 	wholeGreen := q.volume(first, q.MomentsGreen)
 	wholeBlue := q.volume(first, q.MomentsBlue)
 	wholeWeight := q.volume(first, q.Weights)
+	wholeAlpha := 0.0
+	if q.MomentsAlpha != nil {
+		wholeAlpha = q.volume(first, q.MomentsAlpha)
+	}
 
 	// 在每个颜色通道上寻找最佳切割位置
-	maxRed, cutRed := q.maximize(first, RED, wholeRed, wholeGreen, wholeBlue, wholeWeight)
-	maxGreen, cutGreen := q.maximize(first, GREEN, wholeRed, wholeGreen, wholeBlue, wholeWeight)
-	maxBlue, cutBlue := q.maximize(first, BLUE, wholeRed, wholeGreen, wholeBlue, wholeWeight)
+	maxRed, cutRed := q.maximize(first, RED, wholeRed, wholeGreen, wholeBlue, wholeAlpha, wholeWeight)
+	maxGreen, cutGreen := q.maximize(first, GREEN, wholeRed, wholeGreen, wholeBlue, wholeAlpha, wholeWeight)
+	maxBlue, cutBlue := q.maximize(first, BLUE, wholeRed, wholeGreen, wholeBlue, wholeAlpha, wholeWeight)
+	maxAlpha, cutAlpha := 0.0, -1
+	if q.alphaSide > 1 {
+		maxAlpha, cutAlpha = q.maximize(first, ALPHA, wholeRed, wholeGreen, wholeBlue, wholeAlpha, wholeWeight)
+	}
 
 	// 确定哪个颜色通道的切割效果最好
 	direction := RED
@@ -445,10 +740,14 @@ func (q *Quantizer) cut(first, second *ColorCube) bool {
 		cut = cutGreen
 	}
 	if maxBlue > max {
-		// max = maxBlue
+		max = maxBlue
 		direction = BLUE
 		cut = cutBlue
 	}
+	if maxAlpha > max {
+		direction = ALPHA
+		cut = cutAlpha
+	}
 
 	if cut < 0 {
 		return false
@@ -476,21 +775,21 @@ func (q *Quantizer) cut(first, second *ColorCube) bool {
 		second.BlueMin = first.BlueMax
 		second.RedMin = first.RedMin
 		second.GreenMin = first.GreenMin
+	case ALPHA:
+		first.AlphaMax = cut
+		second.AlphaMin = first.AlphaMax
 	}
 
 	// 更新体积
-	first.Volume = (first.RedMax - first.RedMin) *
-		(first.GreenMax - first.GreenMin) *
-		(first.BlueMax - first.BlueMin)
-	second.Volume = (second.RedMax - second.RedMin) *
-		(second.GreenMax - second.GreenMin) *
-		(second.BlueMax - second.BlueMin)
+	first.Volume = q.cubeVolume(first)
+	second.Volume = q.cubeVolume(second)
 
 	return true
 }
 
 // maximize 在指定方向上寻找最佳切割位置
-func (q *Quantizer) maximize(cube *ColorCube, direction int, wholeRed, wholeGreen, wholeBlue, wholeWeight float64) (float64, int) {
+// 量化 Alpha 时 Alpha 的一阶矩同样参与距离计算
+func (q *Quantizer) maximize(cube *ColorCube, direction int, wholeRed, wholeGreen, wholeBlue, wholeAlpha, wholeWeight float64) (float64, int) {
 	max := 0.0
 	cutPosition := -1
 
@@ -507,18 +806,25 @@ func (q *Quantizer) maximize(cube *ColorCube, direction int, wholeRed, wholeGree
 			continue
 		}
 
-		halfDistance := (halfRed*halfRed + halfGreen*halfGreen + halfBlue*halfBlue) / halfWeight
+		halfAlpha := 0.0
+		if q.MomentsAlpha != nil {
+			halfAlpha = q.bottom(cube, direction, q.MomentsAlpha) + q.top(cube, direction, position, q.MomentsAlpha)
+		}
+
+		halfDistance := (halfRed*halfRed + halfGreen*halfGreen + halfBlue*halfBlue + halfAlpha*halfAlpha) / halfWeight
 
 		remainingRed := wholeRed - halfRed
 		remainingGreen := wholeGreen - halfGreen
 		remainingBlue := wholeBlue - halfBlue
+		remainingAlpha := wholeAlpha - halfAlpha
 		remainingWeight := wholeWeight - halfWeight
 
 		if remainingWeight == 0.0 {
 			continue
 		}
 
-		remainingDistance := (remainingRed*remainingRed + remainingGreen*remainingGreen + remainingBlue*remainingBlue) / remainingWeight
+		remainingDistance := (remainingRed*remainingRed + remainingGreen*remainingGreen + remainingBlue*remainingBlue +
+			remainingAlpha*remainingAlpha) / remainingWeight
 
 		temp := halfDistance + remainingDistance
 
@@ -532,36 +838,57 @@ func (q *Quantizer) maximize(cube *ColorCube, direction int, wholeRed, wholeGree
 }
 
 // volume 计算指定立方体在某个矩上的体积
+// 量化 Alpha 时为 AlphaMax 层与 AlphaMin 层上三维体积之差
 func (q *Quantizer) volume(cube *ColorCube, moment []float64) float64 {
-	res := moment[getIndex(cube.RedMax, cube.GreenMax, cube.BlueMax)] -
-		moment[getIndex(cube.RedMax, cube.GreenMax, cube.BlueMin)] -
-		moment[getIndex(cube.RedMax, cube.GreenMin, cube.BlueMax)] +
-		moment[getIndex(cube.RedMax, cube.GreenMin, cube.BlueMin)] -
-		moment[getIndex(cube.RedMin, cube.GreenMax, cube.BlueMax)] +
-		moment[getIndex(cube.RedMin, cube.GreenMax, cube.BlueMin)] +
-		moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMax)] -
-		moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin)]
+	if q.alphaSide == 1 {
+		return q.volumeAt(cube, 0, moment)
+	}
+	return q.volumeAt(cube, cube.AlphaMax, moment) - q.volumeAt(cube, cube.AlphaMin, moment)
+}
+
+// volumeAt 计算立方体在第 a 个 Alpha 层上的红绿蓝三维体积
+func (q *Quantizer) volumeAt(cube *ColorCube, a int, moment []float64) float64 {
+	res := moment[q.getIndex(cube.RedMax, cube.GreenMax, cube.BlueMax, a)] -
+		moment[q.getIndex(cube.RedMax, cube.GreenMax, cube.BlueMin, a)] -
+		moment[q.getIndex(cube.RedMax, cube.GreenMin, cube.BlueMax, a)] +
+		moment[q.getIndex(cube.RedMax, cube.GreenMin, cube.BlueMin, a)] -
+		moment[q.getIndex(cube.RedMin, cube.GreenMax, cube.BlueMax, a)] +
+		moment[q.getIndex(cube.RedMin, cube.GreenMax, cube.BlueMin, a)] +
+		moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMax, a)] -
+		moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin, a)]
 	return res
 }
 
 // top 计算指定立方体在切割方向上的上半部分
 func (q *Quantizer) top(cube *ColorCube, direction, position int, moment []float64) float64 {
+	if direction == ALPHA {
+		return q.volumeAt(cube, position, moment)
+	}
+	if q.alphaSide == 1 {
+		return q.topAt(cube, direction, position, 0, moment)
+	}
+	return q.topAt(cube, direction, position, cube.AlphaMax, moment) -
+		q.topAt(cube, direction, position, cube.AlphaMin, moment)
+}
+
+// topAt 计算立方体在第 a 个 Alpha 层上、红绿蓝切割方向上的上半部分
+func (q *Quantizer) topAt(cube *ColorCube, direction, position, a int, moment []float64) float64 {
 	switch direction {
 	case RED:
-		return moment[getIndex(position, cube.GreenMax, cube.BlueMax)] -
-			moment[getIndex(position, cube.GreenMax, cube.BlueMin)] -
-			moment[getIndex(position, cube.GreenMin, cube.BlueMax)] +
-			moment[getIndex(position, cube.GreenMin, cube.BlueMin)]
+		return moment[q.getIndex(position, cube.GreenMax, cube.BlueMax, a)] -
+			moment[q.getIndex(position, cube.GreenMax, cube.BlueMin, a)] -
+			moment[q.getIndex(position, cube.GreenMin, cube.BlueMax, a)] +
+			moment[q.getIndex(position, cube.GreenMin, cube.BlueMin, a)]
 	case GREEN:
-		return moment[getIndex(cube.RedMax, position, cube.BlueMax)] -
-			moment[getIndex(cube.RedMax, position, cube.BlueMin)] -
-			moment[getIndex(cube.RedMin, position, cube.BlueMax)] +
-			moment[getIndex(cube.RedMin, position, cube.BlueMin)]
+		return moment[q.getIndex(cube.RedMax, position, cube.BlueMax, a)] -
+			moment[q.getIndex(cube.RedMax, position, cube.BlueMin, a)] -
+			moment[q.getIndex(cube.RedMin, position, cube.BlueMax, a)] +
+			moment[q.getIndex(cube.RedMin, position, cube.BlueMin, a)]
 	case BLUE:
-		return moment[getIndex(cube.RedMax, cube.GreenMax, position)] -
-			moment[getIndex(cube.RedMax, cube.GreenMin, position)] -
-			moment[getIndex(cube.RedMin, cube.GreenMax, position)] +
-			moment[getIndex(cube.RedMin, cube.GreenMin, position)]
+		return moment[q.getIndex(cube.RedMax, cube.GreenMax, position, a)] -
+			moment[q.getIndex(cube.RedMax, cube.GreenMin, position, a)] -
+			moment[q.getIndex(cube.RedMin, cube.GreenMax, position, a)] +
+			moment[q.getIndex(cube.RedMin, cube.GreenMin, position, a)]
 	default:
 		return 0.0
 	}
@@ -569,22 +896,34 @@ func (q *Quantizer) top(cube *ColorCube, direction, position int, moment []float
 
 // bottom 计算指定立方体在切割方向上的下半部分
 func (q *Quantizer) bottom(cube *ColorCube, direction int, moment []float64) float64 {
+	if direction == ALPHA {
+		return -q.volumeAt(cube, cube.AlphaMin, moment)
+	}
+	if q.alphaSide == 1 {
+		return q.bottomAt(cube, direction, 0, moment)
+	}
+	return q.bottomAt(cube, direction, cube.AlphaMax, moment) -
+		q.bottomAt(cube, direction, cube.AlphaMin, moment)
+}
+
+// bottomAt 计算立方体在第 a 个 Alpha 层上、红绿蓝切割方向上的下半部分
+func (q *Quantizer) bottomAt(cube *ColorCube, direction, a int, moment []float64) float64 {
 	switch direction {
 	case RED:
-		return -moment[getIndex(cube.RedMin, cube.GreenMax, cube.BlueMax)] +
-			moment[getIndex(cube.RedMin, cube.GreenMax, cube.BlueMin)] +
-			moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMax)] -
-			moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin)]
+		return -moment[q.getIndex(cube.RedMin, cube.GreenMax, cube.BlueMax, a)] +
+			moment[q.getIndex(cube.RedMin, cube.GreenMax, cube.BlueMin, a)] +
+			moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMax, a)] -
+			moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin, a)]
 	case GREEN:
-		return -moment[getIndex(cube.RedMax, cube.GreenMin, cube.BlueMax)] +
-			moment[getIndex(cube.RedMax, cube.GreenMin, cube.BlueMin)] +
-			moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMax)] -
-			moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin)]
+		return -moment[q.getIndex(cube.RedMax, cube.GreenMin, cube.BlueMax, a)] +
+			moment[q.getIndex(cube.RedMax, cube.GreenMin, cube.BlueMin, a)] +
+			moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMax, a)] -
+			moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin, a)]
 	case BLUE:
-		return -moment[getIndex(cube.RedMax, cube.GreenMax, cube.BlueMin)] +
-			moment[getIndex(cube.RedMax, cube.GreenMin, cube.BlueMin)] +
-			moment[getIndex(cube.RedMin, cube.GreenMax, cube.BlueMin)] -
-			moment[getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin)]
+		return -moment[q.getIndex(cube.RedMax, cube.GreenMax, cube.BlueMin, a)] +
+			moment[q.getIndex(cube.RedMax, cube.GreenMin, cube.BlueMin, a)] +
+			moment[q.getIndex(cube.RedMin, cube.GreenMax, cube.BlueMin, a)] -
+			moment[q.getIndex(cube.RedMin, cube.GreenMin, cube.BlueMin, a)]
 	default:
 		return 0.0
 	}
@@ -599,6 +938,10 @@ func (q *Quantizer) calculateVariance(cube *ColorCube) float64 {
 	weight := q.volume(cube, q.Weights)
 
 	distance := volumeRed*volumeRed + volumeGreen*volumeGreen + volumeBlue*volumeBlue
+	if q.MomentsAlpha != nil {
+		volumeAlpha := q.volume(cube, q.MomentsAlpha)
+		distance += volumeAlpha * volumeAlpha
+	}
 
 	return volumeMoment - (distance / weight)
 }
@@ -612,6 +955,8 @@ func (q *Quantizer) cubeMin(cube *ColorCube, direction int) int {
 		return cube.GreenMin
 	case BLUE:
 		return cube.BlueMin
+	case ALPHA:
+		return cube.AlphaMin
 	default:
 		return 0
 	}
@@ -626,6 +971,8 @@ func (q *Quantizer) cubeMax(cube *ColorCube, direction int) int {
 		return cube.GreenMax
 	case BLUE:
 		return cube.BlueMax
+	case ALPHA:
+		return cube.AlphaMax
 	default:
 		return 0
 	}
@@ -637,6 +984,26 @@ func (q *Quantizer) MapPixels() *ColorMap {
 	height := q.Bitmap.Height
 	colors := q.Palette
 	mappedIndices := NewUint8Array2D(width, height)
+	q.preparePaletteLookup()
+
+	if kernel, ok := ditherKernels[q.Dither.Mode]; ok {
+		q.mapPixelsDiffusion(mappedIndices, kernel)
+		return &ColorMap{
+			Width:         width,
+			Height:        height,
+			Colors:        colors,
+			MappedIndices: mappedIndices,
+		}
+	}
+	if tm := orderedDitherMap(q.Dither.Mode); tm != nil {
+		q.mapPixelsOrdered(mappedIndices, tm)
+		return &ColorMap{
+			Width:         width,
+			Height:        height,
+			Colors:        colors,
+			MappedIndices: mappedIndices,
+		}
+	}
 
 	index := 0
 	for i := 0; i < len(q.Bitmap.Data); i += 4 {
@@ -657,12 +1024,49 @@ func (q *Quantizer) MapPixels() *ColorMap {
 	}
 }
 
+// preparePaletteLookup 预先计算调色板在工作颜色空间中的坐标，并构建最近颜色查找结构
+func (q *Quantizer) preparePaletteLookup() {
+	if q.ColorSpace == COLOR_SPACE_RGB {
+		q.paletteWorking = nil
+	} else {
+		q.paletteWorking = make([][4]float64, len(q.Palette))
+		for i, color := range q.Palette {
+			c := toWorkingSpace(q.ColorSpace, color[0], color[1], color[2])
+			q.paletteWorking[i] = [4]float64{c[0], c[1], c[2], float64(color[3])}
+		}
+	}
+	q.lookup = newPaletteLookup(q.ColorSpace, q.searchPalette(), q.paletteWorking)
+}
+
+// searchPalette 返回参与最近颜色查找的调色板部分，透明颜色位于末尾，不参与查找
+func (q *Quantizer) searchPalette() [][4]uint8 {
+	if q.TransparentIndex >= 0 && q.TransparentIndex == len(q.Palette)-1 {
+		return q.Palette[:q.TransparentIndex]
+	}
+	return q.Palette
+}
+
 // findClosestPaletteIndex 找到最接近的调色板颜色的索引
 func (q *Quantizer) findClosestPaletteIndex(color [4]uint8) uint8 {
+	if q.TransparentIndex >= 0 && color[3] < q.Alpha.Threshold {
+		return uint8(q.TransparentIndex)
+	}
+	if q.lookup != nil && q.lookup.matches(q.searchPalette()) {
+		return q.lookup.find(color)
+	}
+	return q.findClosestPaletteIndexLinear(color)
+}
+
+// findClosestPaletteIndexLinear 线性扫描整个调色板查找最接近的颜色
+func (q *Quantizer) findClosestPaletteIndexLinear(color [4]uint8) uint8 {
+	if q.ColorSpace != COLOR_SPACE_RGB {
+		return q.findClosestPaletteIndexWorking(color)
+	}
+
 	minDistance := uint32(math.MaxUint32)
 	closestIndex := uint8(0)
 
-	for idx, paletteColor := range q.Palette {
+	for idx, paletteColor := range q.searchPalette() {
 		distance := q.colorDistanceSquared(color, paletteColor)
 		if distance < minDistance {
 			minDistance = distance
@@ -673,8 +1077,39 @@ func (q *Quantizer) findClosestPaletteIndex(color [4]uint8) uint8 {
 	return closestIndex
 }
 
+// findClosestPaletteIndexWorking 在工作颜色空间中查找最接近的调色板颜色
+func (q *Quantizer) findClosestPaletteIndexWorking(color [4]uint8) uint8 {
+	if len(q.paletteWorking) != len(q.Palette) {
+		q.preparePaletteLookup()
+	}
+
+	c := toWorkingSpace(q.ColorSpace, color[0], color[1], color[2])
+	minDistance := math.MaxFloat64
+	closestIndex := uint8(0)
+
+	// Alpha 作为第四个坐标参与比较，调色板 Alpha 相同时不影响结果
+	for idx, p := range q.paletteWorking[:len(q.searchPalette())] {
+		d0 := c[0] - p[0]
+		d1 := c[1] - p[1]
+		d2 := c[2] - p[2]
+		d3 := float64(color[3]) - p[3]
+		distance := d0*d0 + d1*d1 + d2*d2 + d3*d3
+		if distance < minDistance {
+			minDistance = distance
+			closestIndex = uint8(idx)
+		}
+	}
+
+	return closestIndex
+}
+
 // colorDistanceSquared 计算两个颜色之间的欧几里得距离的平方
 func (q *Quantizer) colorDistanceSquared(color1, color2 [4]uint8) uint32 {
+	return colorDistanceSquared(color1, color2)
+}
+
+// colorDistanceSquared 计算两个 RGBA 颜色之间的欧几里得距离的平方
+func colorDistanceSquared(color1, color2 [4]uint8) uint32 {
 	rDiff := int32(color1[0]) - int32(color2[0])
 	gDiff := int32(color1[1]) - int32(color2[1])
 	bDiff := int32(color1[2]) - int32(color2[2])
@@ -0,0 +1,140 @@
+package main
+
+// 量化算法
+const (
+	QUANTIZER_WU         = iota // Wu 方差最小化分割（默认）
+	QUANTIZER_MEDIAN_CUT        // 中位切分
+	QUANTIZER_OCTREE            // 八叉树
+	QUANTIZER_NEUQUANT          // NeuQuant 神经网络
+)
+
+// ColorQuantizer 颜色量化算法接口
+// BuildPalette 根据构造时传入的 Bitmap 生成调色板，MapPixels 将像素映射到调色板得到 ColorMap，
+// Quantize 依次执行两者并返回量化后的 Bitmap。合并、排序等后处理直接作用于 ColorMap，见 MergeSimilarColors 和 SortPalette
+type ColorQuantizer interface {
+	BuildPalette() [][4]uint8
+	MapPixels() *ColorMap
+	Quantize() *Bitmap
+}
+
+// colorSample 参与调色板生成的一种颜色及其像素数
+type colorSample struct {
+	color [4]float64 // 工作颜色空间中的坐标及 Alpha
+	count float64
+}
+
+// NewColorQuantizer 根据算法创建量化器
+// 所有算法共用 Quantizer 的像素映射、抖动、透明度和锁定颜色处理，K-means 优化仅用于 Wu 算法
+func NewColorQuantizer(algorithm int, bitmap *Bitmap, colors int, options QuantizerOptions) ColorQuantizer {
+	switch algorithm {
+	case QUANTIZER_MEDIAN_CUT:
+		return NewMedianCutQuantizer(bitmap, colors, options)
+	case QUANTIZER_OCTREE:
+		return NewOctreeQuantizer(bitmap, colors, options)
+	case QUANTIZER_NEUQUANT:
+		return NewNeuQuantQuantizer(bitmap, colors, options)
+	default:
+		return NewQuantizerWithOptions(bitmap, colors, options)
+	}
+}
+
+// ParseQuantizerAlgorithm 根据名称返回量化算法，未知名称返回 QUANTIZER_WU
+func ParseQuantizerAlgorithm(name string) int {
+	switch name {
+	case "median-cut", "mediancut", "median":
+		return QUANTIZER_MEDIAN_CUT
+	case "octree":
+		return QUANTIZER_OCTREE
+	case "neuquant", "nq":
+		return QUANTIZER_NEUQUANT
+	default:
+		return QUANTIZER_WU
+	}
+}
+
+// newSampledQuantizer 创建不使用 Wu 直方图的 Quantizer，供其他算法生成调色板并复用像素映射
+func newSampledQuantizer(bitmap *Bitmap, colors int, options QuantizerOptions) *Quantizer {
+	locked := limitLockedColors(options.LockedColors, options.Alpha)
+
+	quant := &Quantizer{
+		Colors:       normalizeColorCount(colors, locked),
+		Bitmap:       bitmap.Clone(),
+		ColorSpace:   options.ColorSpace,
+		Dither:       options.Dither,
+		Alpha:        options.Alpha,
+		LockedColors: locked,
+
+		TransparentIndex: -1,
+		lockedRadius:     options.LockedRadius,
+	}
+	quant.lockedWorking = lockedWorkingColors(options.ColorSpace, locked)
+
+	for i := 3; i < len(bitmap.Data); i += 4 {
+		if bitmap.Data[i] < options.Alpha.Threshold {
+			quant.transparentPixels++
+		}
+	}
+	return quant
+}
+
+// pixelWorking 返回第 i 个像素在工作颜色空间中的坐标及 Alpha
+// 透明像素和已由锁定颜色表示的像素不参与调色板生成，返回 false
+func (q *Quantizer) pixelWorking(i int) ([4]float64, bool) {
+	pos := i * 4
+	r, g, b, a := q.Bitmap.Data[pos], q.Bitmap.Data[pos+1], q.Bitmap.Data[pos+2], q.Bitmap.Data[pos+3]
+	if a < q.Alpha.Threshold {
+		return [4]float64{}, false
+	}
+
+	c := toWorkingSpace(q.ColorSpace, r, g, b)
+	if len(q.lockedWorking) > 0 && q.coveredByLockedColor(c) {
+		return [4]float64{}, false
+	}
+
+	// 不量化 Alpha 时所有颜色的 Alpha 相同，不影响算法结果
+	alpha := 255.0
+	if q.Alpha.QuantizeAlpha {
+		alpha = float64(a)
+	}
+	return [4]float64{c[0], c[1], c[2], alpha}, true
+}
+
+// collectSamples 统计参与调色板生成的所有不同颜色及其像素数
+func (q *Quantizer) collectSamples() []colorSample {
+	pixelCount := len(q.Bitmap.Data) / 4
+	indices := make(map[uint32]int) // 颜色键到 samples 的索引，-1 表示不参与
+	samples := make([]colorSample, 0)
+
+	for i := 0; i < pixelCount; i++ {
+		pos := i * 4
+		key := uint32(q.Bitmap.Data[pos])<<24 | uint32(q.Bitmap.Data[pos+1])<<16 | uint32(q.Bitmap.Data[pos+2])<<8
+		if q.Alpha.QuantizeAlpha {
+			key |= uint32(q.Bitmap.Data[pos+3])
+		} else if q.Bitmap.Data[pos+3] < q.Alpha.Threshold {
+			key |= 1
+		}
+
+		index, ok := indices[key]
+		if !ok {
+			index = -1
+			if color, participates := q.pixelWorking(i); participates {
+				index = len(samples)
+				samples = append(samples, colorSample{color: color})
+			}
+			indices[key] = index
+		}
+		if index >= 0 {
+			samples[index].count++
+		}
+	}
+	return samples
+}
+
+// samplesToPalette 将工作颜色空间中的颜色转换为调色板颜色
+func (q *Quantizer) samplesToPalette(colors [][4]float64) [][4]uint8 {
+	palette := make([][4]uint8, 0, len(colors))
+	for _, c := range colors {
+		palette = append(palette, q.paletteColor(c[0], c[1], c[2], clampToUint8(c[3])))
+	}
+	return palette
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+// gradientBitmap 创建一张水平渐变并带有透明列的测试图像
+func gradientBitmap(width, height uint32) *Bitmap {
+	bmp := NewBitmap(width, height)
+	for y := uint32(0); y < height; y++ {
+		for x := uint32(0); x < width; x++ {
+			pos := (y*width + x) * 4
+			bmp.Data[pos] = uint8(x * 255 / width)
+			bmp.Data[pos+1] = uint8(y * 255 / height)
+			bmp.Data[pos+2] = uint8(255 - x*255/width)
+			bmp.Data[pos+3] = 255
+			if x == 0 {
+				bmp.Data[pos+3] = 0
+			}
+		}
+	}
+	return bmp
+}
+
+func TestQuantizeAllAlgorithms(t *testing.T) {
+	algorithms := []int{QUANTIZER_WU, QUANTIZER_MEDIAN_CUT, QUANTIZER_OCTREE, QUANTIZER_NEUQUANT}
+	for _, algorithm := range algorithms {
+		for _, options := range []QuantizerOptions{{}, DefaultQuantizerOptions()} {
+			bmp := gradientBitmap(32, 16)
+			result := NewColorQuantizer(algorithm, bmp, 8, options).Quantize()
+			if result.Width != bmp.Width || result.Height != bmp.Height || len(result.Data) != len(bmp.Data) {
+				t.Fatalf("algorithm %d: result is %dx%d with %d bytes", algorithm, result.Width, result.Height, len(result.Data))
+			}
+
+			quantizer := NewColorQuantizer(algorithm, bmp, 8, options)
+			palette := quantizer.BuildPalette()
+			colorMap := quantizer.MapPixels()
+			if len(palette) == 0 || len(palette) > 8 || len(colorMap.Colors) != len(palette) {
+				t.Fatalf("algorithm %d: palette has %d colors, color map %d", algorithm, len(palette), len(colorMap.Colors))
+			}
+			for i, index := range colorMap.MappedIndices.Data {
+				if int(index) >= len(palette) {
+					t.Fatalf("algorithm %d: pixel %d mapped to %d of %d colors", algorithm, i, index, len(palette))
+				}
+			}
+
+			// 渐变每个分量的方差约为 5400，8 种颜色均匀分割时均方误差约为 680
+			metrics := ComputeQualityMetrics(bmp, colorMap)
+			if metrics.PixelCount != len(colorMap.MappedIndices.Data) || metrics.MSE > 900 {
+				t.Fatalf("algorithm %d: MSE %.1f over %d pixels", algorithm, metrics.MSE, metrics.PixelCount)
+			}
+		}
+	}
+}
+
+func TestBuildPaletteRespectsColorCount(t *testing.T) {
+	options := DefaultQuantizerOptions()
+	options.Alpha.Threshold = 128
+	for _, algorithm := range []int{QUANTIZER_WU, QUANTIZER_MEDIAN_CUT, QUANTIZER_OCTREE, QUANTIZER_NEUQUANT} {
+		quantizer := NewColorQuantizer(algorithm, gradientBitmap(32, 16), 8, options)
+		palette := quantizer.BuildPalette()
+		// 7 种自适应颜色加上末尾的透明颜色
+		if len(palette) == 0 || len(palette) > 8 {
+			t.Fatalf("algorithm %d: palette has %d colors", algorithm, len(palette))
+		}
+		if palette[len(palette)-1][3] != 0 {
+			t.Fatalf("algorithm %d: last color %v is not transparent", algorithm, palette[len(palette)-1])
+		}
+
+		colorMap := quantizer.MapPixels()
+		if index := colorMap.MappedIndices.Get(0, 0); int(index) != len(palette)-1 {
+			t.Fatalf("algorithm %d: transparent pixel mapped to %d", algorithm, index)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestQuantizeAlphaSplitsOnAlpha(t *testing.T) {
+	// 同一种 RGB 颜色，一半像素不透明，一半半透明
+	bmp := NewBitmap(8, 8)
+	for i := 0; i < len(bmp.Data); i += 4 {
+		bmp.Data[i] = 200
+		bmp.Data[i+3] = 255
+		if i >= len(bmp.Data)/2 {
+			bmp.Data[i+3] = 60
+		}
+	}
+
+	options := DefaultQuantizerOptions()
+	options.Alpha.QuantizeAlpha = true
+	quantizer := NewQuantizerWithOptions(bmp, 2, options)
+	palette := quantizer.BuildPalette()
+	if len(palette) != 2 {
+		t.Fatalf("palette = %v, want 2 colors", palette)
+	}
+	alphas := map[uint8]bool{palette[0][3]: true, palette[1][3]: true}
+	if !alphas[255] || !alphas[60] {
+		t.Fatalf("palette = %v, want alpha 255 and 60", palette)
+	}
+
+	colorMap := quantizer.MapPixels()
+	for i, index := range colorMap.MappedIndices.Data {
+		if palette[index][3] != bmp.Data[i*4+3] {
+			t.Fatalf("pixel %d with alpha %d mapped to %v", i, bmp.Data[i*4+3], palette[index])
+		}
+	}
+}
+
+func TestHistogramBitsWithinLimit(t *testing.T) {
+	for _, maxBytes := range []int{0, 1 << 20, 256 << 20} {
+		limit := maxBytes
+		if limit == 0 {
+			limit = DEFAULT_MAX_HISTOGRAM_BYTES
+		}
+		for _, quantizeAlpha := range []bool{false, true} {
+			for bits := MIN_SIGNIFICANT_BITS; bits <= MAX_SIGNIFICANT_BITS; bits++ {
+				used := histogramBits(bits, quantizeAlpha, maxBytes)
+				if used > bits || used < MIN_SIGNIFICANT_BITS {
+					t.Fatalf("histogramBits(%d, %v, %d) = %d", bits, quantizeAlpha, maxBytes, used)
+				}
+				if used > MIN_SIGNIFICANT_BITS && histogramBytes(used, quantizeAlpha) > limit {
+					t.Fatalf("histogramBits(%d, %v, %d) = %d exceeds the memory limit", bits, quantizeAlpha, maxBytes, used)
+				}
+			}
+		}
+	}
+
+	// 实际使用的位数记录在 SignificantBits 中
+	options := DefaultQuantizerOptions()
+	options.HistogramBits = 7
+	options.MaxHistogramBytes = 2 << 20
+	if bits := NewQuantizerWithOptions(gradientBitmap(4, 4), 4, options).SignificantBits; bits != 5 {
+		t.Fatalf("SignificantBits = %d, want 5 under a 2 MB limit", bits)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+// DEFAULT_REFINE_THRESHOLD 默认的 K-means 收敛阈值（工作颜色空间中的欧几里得距离）
+const DEFAULT_REFINE_THRESHOLD = 0.5
+
+// RefineOptions K-means (Lloyd) 调色板优化选项
+type RefineOptions struct {
+	Iterations int     // 最大迭代次数，0 表示不优化
+	Threshold  float64 // 一次迭代中所有颜色的移动距离都小于该值时认为已收敛
+}
+
+// DefaultRefineOptions 返回默认的优化选项，默认不启用
+func DefaultRefineOptions() RefineOptions {
+	return RefineOptions{
+		Iterations: 0,
+		Threshold:  DEFAULT_REFINE_THRESHOLD,
+	}
+}
+
+// histogramCell 直方图中的一个非空单元
+type histogramCell struct {
+	weight float64
+	mean   [4]float64 // 单元内颜色在工作颜色空间中的平均值及平均 Alpha
+}
+
+// histogramCells 从积分矩中还原每个直方图单元的像素数和平均颜色
+// 必须在 calculateMoments 之后调用
+func (q *Quantizer) histogramCells() []histogramCell {
+	cells := make([]histogramCell, 0)
+	cube := NewColorCube()
+
+	// 不量化 Alpha 时只有一层，否则遍历第 1 层之后的每一层
+	firstAlpha := 0
+	if q.alphaSide > 1 {
+		firstAlpha = 1
+	}
+	for a := firstAlpha; a < q.alphaSide; a++ {
+		if q.alphaSide > 1 {
+			cube.AlphaMin, cube.AlphaMax = a-1, a
+		}
+		for r := 1; r < q.sideSize; r++ {
+			for g := 1; g < q.sideSize; g++ {
+				for b := 1; b < q.sideSize; b++ {
+					cube.RedMin, cube.RedMax = r-1, r
+					cube.GreenMin, cube.GreenMax = g-1, g
+					cube.BlueMin, cube.BlueMax = b-1, b
+
+					weight := q.volume(cube, q.Weights)
+					if weight <= 0 {
+						continue
+					}
+
+					cell := histogramCell{weight: weight}
+					cell.mean[0] = q.volume(cube, q.MomentsRed) / weight
+					cell.mean[1] = q.volume(cube, q.MomentsGreen) / weight
+					cell.mean[2] = q.volume(cube, q.MomentsBlue) / weight
+					cell.mean[3] = 255
+					if q.MomentsAlpha != nil {
+						cell.mean[3] = q.volume(cube, q.MomentsAlpha) / weight
+					}
+					cells = append(cells, cell)
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// refinePalette 以 Wu 算法分割出的立方体均值为初始中心，在直方图单元上执行 K-means 迭代
+// 锁定颜色作为固定的中心参与分配但不移动，返回的调色板只包含可调整的颜色，顺序与 preparePalette 一致
+func (q *Quantizer) refinePalette() [][4]uint8 {
+	centers := make([][4]float64, 0, len(q.lockedWorking)+q.Colors)
+	for i, c := range q.lockedWorking {
+		centers = append(centers, [4]float64{c[0], c[1], c[2], float64(q.LockedColors[i][3])})
+	}
+	fixed := len(centers)
+
+	for k := 0; k < q.Colors; k++ {
+		weight := q.volume(q.Cubes[k], q.Weights)
+		if weight <= 0 {
+			continue
+		}
+		center := [4]float64{
+			q.volume(q.Cubes[k], q.MomentsRed) / weight,
+			q.volume(q.Cubes[k], q.MomentsGreen) / weight,
+			q.volume(q.Cubes[k], q.MomentsBlue) / weight,
+			255,
+		}
+		if q.MomentsAlpha != nil {
+			center[3] = q.volume(q.Cubes[k], q.MomentsAlpha) / weight
+		}
+		centers = append(centers, center)
+	}
+
+	cells := q.histogramCells()
+	sums := make([][4]float64, len(centers))
+	weights := make([]float64, len(centers))
+	threshold := q.Refine.Threshold * q.Refine.Threshold
+
+	for iteration := 0; iteration < q.Refine.Iterations; iteration++ {
+		for i := range sums {
+			sums[i] = [4]float64{}
+			weights[i] = 0
+		}
+
+		// 将每个单元分配给最近的中心
+		for _, cell := range cells {
+			closest := nearestCenter(centers, cell.mean)
+			for c := 0; c < 4; c++ {
+				sums[closest][c] += cell.mean[c] * cell.weight
+			}
+			weights[closest] += cell.weight
+		}
+
+		// 将中心移动到所分配单元的加权平均值，没有分配到单元的中心保持不变
+		moved := 0.0
+		for i := fixed; i < len(centers); i++ {
+			if weights[i] <= 0 {
+				continue
+			}
+			var center [4]float64
+			for c := 0; c < 4; c++ {
+				center[c] = sums[i][c] / weights[i]
+			}
+			if d := squaredDistance4(center, centers[i]); d > moved {
+				moved = d
+			}
+			centers[i] = center
+		}
+
+		if moved < threshold {
+			break
+		}
+	}
+
+	q.adaptiveWeights = append([]float64{}, weights[fixed:]...)
+	palette := make([][4]uint8, 0, len(centers)-fixed)
+	for _, center := range centers[fixed:] {
+		palette = append(palette, q.paletteColor(center[0], center[1], center[2], clampToUint8(center[3])))
+	}
+	return palette
+}
+
+// nearestCenter 返回距离最小的中心索引，距离相同时取索引最小的中心
+func nearestCenter(centers [][4]float64, point [4]float64) int {
+	closest := 0
+	minDistance := 0.0
+	for i, center := range centers {
+		distance := squaredDistance4(point, center)
+		if i == 0 || distance < minDistance {
+			minDistance = distance
+			closest = i
+		}
+	}
+	return closest
+}
+
+// squaredDistance4 计算两个四维坐标的欧几里得距离平方
+func squaredDistance4(a, b [4]float64) float64 {
+	d0 := a[0] - b[0]
+	d1 := a[1] - b[1]
+	d2 := a[2] - b[2]
+	d3 := a[3] - b[3]
+	return d0*d0 + d1*d1 + d2*d2 + d3*d3
+}
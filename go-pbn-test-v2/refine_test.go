@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// noisyBitmap 创建一张带随机噪声的渐变，使 Wu 的初始调色板有优化的空间
+func noisyBitmap(width, height uint32, seed int64) *Bitmap {
+	rng := rand.New(rand.NewSource(seed))
+	bmp := gradientBitmap(width, height)
+	for i := 0; i < len(bmp.Data); i += 4 {
+		bmp.Data[i+3] = 255
+		for c := 0; c < 3; c++ {
+			bmp.Data[i+c] = clampToUint8(float64(bmp.Data[i+c]) + rng.NormFloat64()*20)
+		}
+	}
+	return bmp
+}
+
+// workingError 量化图像，返回像素与所映射颜色在工作颜色空间中的误差平方和，以及调色板
+// K-means 优化的目标是工作颜色空间中的误差，感知颜色空间中 RGB 误差可能上升
+func workingError(bmp *Bitmap, colors int, options QuantizerOptions) (float64, [][4]uint8) {
+	quantizer := NewQuantizerWithOptions(bmp, colors, options)
+	palette := quantizer.BuildPalette()
+	colorMap := quantizer.MapPixels()
+
+	total := 0.0
+	for i, index := range colorMap.MappedIndices.Data {
+		pos := i * 4
+		p := toWorkingSpace(options.ColorSpace, bmp.Data[pos], bmp.Data[pos+1], bmp.Data[pos+2])
+		color := palette[index]
+		c := toWorkingSpace(options.ColorSpace, color[0], color[1], color[2])
+		for k := 0; k < 3; k++ {
+			total += (p[k] - c[k]) * (p[k] - c[k])
+		}
+	}
+	return total, palette
+}
+
+func TestRefineDoesNotIncreaseError(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		for _, colorSpace := range []int{COLOR_SPACE_RGB, COLOR_SPACE_LAB, COLOR_SPACE_OKLAB} {
+			bmp := noisyBitmap(48, 32, seed)
+			options := DefaultQuantizerOptions()
+			options.ColorSpace = colorSpace
+			before, _ := workingError(bmp, 12, options)
+			options.Refine.Iterations = 10
+			after, _ := workingError(bmp, 12, options)
+			if after > before*1.001 {
+				t.Fatalf("seed %d, color space %d: error rose from %.0f to %.0f", seed, colorSpace, before, after)
+			}
+		}
+	}
+}
+
+func TestRefineKeepsLockedAndFixedColors(t *testing.T) {
+	bmp := noisyBitmap(48, 32, 1)
+	options := DefaultQuantizerOptions()
+	options.Refine.Iterations = 10
+	options.Refine.Threshold = 0
+	options.LockedColors = lockedTestColors
+	options.LockedRadius = 0
+	_, palette := workingError(bmp, 10, options)
+	if !samePalette(palette[:len(lockedTestColors)], lockedTestColors) {
+		t.Fatalf("locked colors moved: %v", palette[:len(lockedTestColors)])
+	}
+
+	catalog := [][4]uint8{{200, 30, 30, 255}, {30, 200, 30, 255}, {30, 30, 200, 255}}
+	quantizer := NewQuantizerWithPalette(bmp, catalog, options)
+	if palette := quantizer.BuildPalette(); !samePalette(palette, catalog) {
+		t.Fatalf("fixed palette moved: %v", palette)
+	}
+}
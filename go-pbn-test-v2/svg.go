@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// SVG 填充模式
+const (
+	SVG_FILL_NONE    = iota // 不填充
+	SVG_FILL_WHITE          // 白色填充，用于打印
+	SVG_FILL_PALETTE        // 调色板颜色填充，用于预览
+)
+
+// SVGOptions SVG 导出选项
+type SVGOptions struct {
+	StrokeWidth float64 // 边界线宽度，0 表示不绘制边界
+	StrokeColor string  // 边界线颜色
+	FillMode    int     // 填充模式
+	ShowLabels  bool    // 是否在区域内绘制颜色编号
+	FontSize    float64 // 编号的最大字号
+	MinFontSize float64 // 编号的最小字号，放不下编号的区域不绘制编号
+	LabelColor  string  // 编号颜色
+}
+
+// DefaultSVGOptions 返回默认的 SVG 导出选项
+func DefaultSVGOptions() SVGOptions {
+	return SVGOptions{
+		StrokeWidth: 1,
+		StrokeColor: "#000000",
+		FillMode:    SVG_FILL_WHITE,
+		ShowLabels:  true,
+		FontSize:    10,
+		MinFontSize: 4,
+		LabelColor:  "#000000",
+	}
+}
+
+// ExportSVG 将量化结果导出为填色模板 SVG，每个区域对应一个 path
+func ExportSVG(colorMap *ColorMap, facetResult *FacetResult, borders *BorderResult, options SVGOptions) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		colorMap.Width, colorMap.Height, colorMap.Width, colorMap.Height)
+
+	// 颜色由调用方传入，写入属性前需要转义
+	stroke := "none"
+	if options.StrokeWidth > 0 {
+		stroke = html.EscapeString(options.StrokeColor)
+	}
+
+	// 区域路径，孔洞通过 evenodd 规则镂空
+	for id, facet := range facetResult.Facets {
+		if facet == nil || id >= len(borders.FacetLoops) || isTransparentFacet(colorMap, facet) {
+			continue
+		}
+
+		fill := "none"
+		switch options.FillMode {
+		case SVG_FILL_WHITE:
+			fill = "#ffffff"
+		case SVG_FILL_PALETTE:
+			if int(facet.ColorIndex) < len(colorMap.Colors) {
+				fill = colorToHex(colorMap.Colors[facet.ColorIndex])
+			}
+		}
+
+		fmt.Fprintf(&sb, `<path data-facet="%d" data-color="%d" d="`, id, facet.ColorIndex)
+		for _, loop := range borders.FacetLoops[id] {
+			writeLoopPath(&sb, borders.LoopPoints(loop))
+		}
+		fmt.Fprintf(&sb, `" fill="%s" fill-rule="evenodd" stroke="%s" stroke-width="%s" stroke-linejoin="round"/>`+"\n",
+			fill, stroke, formatFloat(options.StrokeWidth))
+	}
+
+	// 颜色编号
+	if options.ShowLabels {
+		fmt.Fprintf(&sb, `<g font-family="Arial, sans-serif" font-size="%s" fill="%s" text-anchor="middle" dominant-baseline="central">`+"\n",
+			formatFloat(options.FontSize), html.EscapeString(options.LabelColor))
+		labels := PlaceLabels(facetResult, LabelOptions{
+			MinFontSize: options.MinFontSize,
+			MaxFontSize: options.FontSize,
+		})
+		for _, label := range labels {
+			if label == nil || !label.Fits || isTransparentFacet(colorMap, facetResult.Facets[label.FacetID]) {
+				continue
+			}
+			fmt.Fprintf(&sb, `<text x="%s" y="%s" font-size="%s">%s</text>`+"\n",
+				formatFloat(label.X), formatFloat(label.Y), formatFloat(label.FontSize), label.Text)
+		}
+		sb.WriteString("</g>\n")
+	}
+
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+// isTransparentFacet 判断区域是否为完全透明的颜色，透明区域不绘制
+func isTransparentFacet(colorMap *ColorMap, facet *Facet) bool {
+	return int(facet.ColorIndex) < len(colorMap.Colors) && colorMap.Colors[facet.ColorIndex][3] == 0
+}
+
+// writeLoopPath 将闭合折线写为 SVG 路径命令
+func writeLoopPath(sb *strings.Builder, points []Point) {
+	if len(points) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "M%d %d", points[0].X, points[0].Y)
+	for _, p := range points[1:] {
+		fmt.Fprintf(sb, "L%d %d", p.X, p.Y)
+	}
+	sb.WriteString("Z")
+}
+
+// colorToHex 将 RGBA 颜色转换为 #rrggbb 格式
+func colorToHex(color [4]uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", color[0], color[1], color[2])
+}
+
+// formatFloat 格式化浮点数，去掉多余的小数位
+func formatFloat(value float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportSVGEscapesColors(t *testing.T) {
+	colorMap := NewColorMap(2, 1, [][4]uint8{{255, 0, 0, 255}, {0, 0, 255, 255}})
+	colorMap.SetPixelIndex(1, 0, 1)
+	facetResult := BuildFacets(colorMap)
+
+	options := DefaultSVGOptions()
+	options.StrokeColor = `red" onload="alert(1)`
+	options.LabelColor = `<script>`
+	svg := ExportSVG(colorMap, facetResult, TraceBorders(facetResult), options)
+
+	if strings.Contains(svg, `" onload="`) || strings.Contains(svg, "<script>") {
+		t.Fatalf("colors are not escaped:\n%s", svg)
+	}
+	if !strings.Contains(svg, `stroke="red&#34; onload=&#34;alert(1)"`) {
+		t.Fatalf("escaped stroke color not found:\n%s", svg)
+	}
+}